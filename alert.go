@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// alertResponse rings the bell and sends a desktop notification, as
+// configured, when a response arrives while the window is in the
+// background or after a long wait. Like the OSC 52 copy, the sequences go
+// to stderr so they do not get in the way of the renderer.
+func (m model) alertResponse(text string, latency time.Duration) {
+	slow := m.alert.AfterSeconds > 0 && latency >= time.Duration(m.alert.AfterSeconds)*time.Second
+	if m.focused && !slow {
+		return
+	}
+	if m.alert.Bell {
+		fmt.Fprint(os.Stderr, "\a")
+	}
+	if m.alert.Desktop {
+		body, _, _ := strings.Cut(strings.TrimSpace(ansi.Strip(text)), "\n")
+		body = strings.NewReplacer(";", ",", "\a", "").Replace(ansi.Truncate(body, 80, "…"))
+		fmt.Fprintf(os.Stderr, "\x1b]777;notify;relay;%s\a", body)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Anthropic's Messages API, the claude CLI's alternative when there is an
+// API key rather than a login.
+
+const (
+	ANTHROPIC_BASE_URL   = "https://api.anthropic.com"
+	ANTHROPIC_VERSION    = "2023-06-01"
+	ANTHROPIC_MODEL      = "claude-sonnet-4-5" // when the chat sets none
+	ANTHROPIC_MAX_TOKENS = 8192                // the API wants a limit
+)
+
+// anthropicAliases let "/model opus" work as it does with the claude CLI.
+var anthropicAliases = map[string]string{
+	"opus":   "claude-opus-4-1",
+	"sonnet": "claude-sonnet-4-5",
+	"haiku":  "claude-haiku-4-5",
+}
+
+// anthropicUsage is how Anthropic counts tokens, with the prompt split by
+// what was read from or written to the prompt cache. claude-code reports
+// it too.
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+}
+
+func (u anthropicUsage) prompt() int {
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+// anthropicRequest is where path is on the server and the header that
+// authorizes the key.
+func anthropicRequest(path string) (string, http.Header, error) {
+	key := os.Getenv("ANTHROPIC_API_KEY")
+	if key == "" {
+		return "", nil, errors.New("ANTHROPIC_API_KEY is not set")
+	}
+	header := http.Header{}
+	header.Set("x-api-key", key)
+	header.Set("anthropic-version", ANTHROPIC_VERSION)
+	return strings.TrimSuffix(cmp.Or(os.Getenv("ANTHROPIC_BASE_URL"), ANTHROPIC_BASE_URL), "/") + path, header, nil
+}
+
+func anthropicChat(ctx context.Context, req apiRequest, chunks chan<- string) (apiReply, error) {
+	url, header, err := anthropicRequest("/v1/messages")
+	if err != nil {
+		return apiReply{}, err
+	}
+
+	model := cmp.Or(req.model, ANTHROPIC_MODEL)
+	if full, ok := anthropicAliases[model]; ok {
+		model = full
+	}
+	body := map[string]any{
+		"model":      model,
+		"max_tokens": cmp.Or(req.params.MaxTokens, ANTHROPIC_MAX_TOKENS),
+		"messages":   req.messages,
+		"stream":     true,
+	}
+	if req.system != "" {
+		body["system"] = req.system
+	}
+	if p := req.params; p.Temperature != nil {
+		body["temperature"] = *p.Temperature
+	}
+	if p := req.params; p.TopP != nil {
+		body["top_p"] = *p.TopP
+	}
+	resp, err := postJSON(ctx, url, header, body)
+	if err != nil {
+		return apiReply{}, err
+	}
+	defer resp.Body.Close()
+
+	var out strings.Builder
+	var usage Usage
+	err = readEvents(resp.Body, func(data string) (bool, error) {
+		var event struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Message struct {
+				Usage anthropicUsage `json:"usage"`
+			} `json:"message"`
+			Usage anthropicUsage `json:"usage"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return false, err
+		}
+		switch event.Type {
+		case "message_start":
+			usage.Prompt = event.Message.Usage.prompt()
+		case "message_delta":
+			// the count so far, not an increment
+			usage.Completion = event.Usage.OutputTokens
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				out.WriteString(event.Delta.Text)
+				if chunks != nil {
+					chunks <- event.Delta.Text
+				}
+			}
+		case "message_stop":
+			return true, nil
+		case "error":
+			// the status the error would have had before the stream started
+			return false, statusError{code: anthropicStatus[event.Error.Type], status: strings.ReplaceAll(event.Error.Type, "_", " "), message: event.Error.Message}
+		}
+		return false, nil
+	})
+	return apiReply{text: out.String(), usage: usage}, err
+}
+
+// anthropicStatus maps the error types of a stream to their HTTP status.
+var anthropicStatus = map[string]int{
+	"invalid_request_error": http.StatusBadRequest,
+	"authentication_error":  http.StatusUnauthorized,
+	"permission_error":      http.StatusForbidden,
+	"not_found_error":       http.StatusNotFound,
+	"request_too_large":     http.StatusRequestEntityTooLarge,
+	"rate_limit_error":      http.StatusTooManyRequests,
+	"api_error":             http.StatusInternalServerError,
+	"overloaded_error":      529,
+}
+
+// anthropicModels lists the models the key can use.
+func anthropicModels(ctx context.Context) ([]string, error) {
+	url, header, err := anthropicRequest("/v1/models")
+	if err != nil {
+		return nil, err
+	}
+	var list modelList
+	if err := getJSON(ctx, url, header, &list); err != nil {
+		return nil, err
+	}
+	return list.names(), nil
+}
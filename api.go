@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Some providers get the conversation as messages, with the system prompt
+// apart, instead of one prompt: the HTTP APIs, and claude-code, which
+// keeps the conversation on its side.
+
+type apiMessage struct {
+	Role    string `json:"role"` // "user" or "assistant"
+	Content string `json:"content"`
+}
+
+type apiRequest struct {
+	model    string
+	system   string
+	messages []apiMessage
+	params   Params
+	session  string // the backend's id for the conversation, to go on with it; messages then hold only the new input
+}
+
+type apiReply struct {
+	text    string
+	session string // the id to go on with the conversation, for backends that keep it
+	usage   Usage  // zero when the backend did not report it
+}
+
+// apiProviders send the request and the response text on chunks as it
+// streams in, if chunks is not nil, and return all of it.
+var apiProviders = map[string]func(ctx context.Context, req apiRequest, chunks chan<- string) (apiReply, error){
+	"openai":    openaiChat,
+	"anthropic": anthropicChat,
+	"ollama":    ollamaChat,
+	CLAUDE_CODE: claudeCode,
+}
+
+func isAPI(provider string) bool {
+	_, ok := apiProviders[provider]
+	return ok
+}
+
+// streamAPI is streamBackend for the HTTP providers.
+func streamAPI(ctx context.Context, provider string, req apiRequest, chunks chan<- string) (apiReply, error) {
+	call, ok := apiProviders[provider]
+	if !ok {
+		return apiReply{}, fmt.Errorf("unknown provider %q", provider)
+	}
+	timeout := timeoutFor(provider)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	out, err := filterReply(provider, chunks, func(chunks chan<- string) (apiReply, error) {
+		return call(ctx, req, chunks)
+	})
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("%s gave no answer within %s", provider, timeout)
+	}
+	return out, err
+}
+
+// filterReply runs the reply of call through the provider's filters, if
+// it has any, both as it streams in and once it is done.
+func filterReply(provider string, chunks chan<- string, call func(chunks chan<- string) (apiReply, error)) (apiReply, error) {
+	names := filtersFor(provider)
+	if len(names) == 0 {
+		return call(chunks)
+	}
+	filtered := newPipeline(names)
+	var raw chan string
+	done := make(chan struct{})
+	if chunks != nil {
+		raw = make(chan string, cap(chunks))
+		go func() {
+			defer close(done)
+			for chunk := range raw {
+				if text := filtered.write(chunk); text != "" {
+					chunks <- text
+				}
+			}
+		}()
+	}
+	reply, err := call(raw)
+	if chunks == nil {
+		reply.text = filterText(names, reply.text)
+		return reply, err
+	}
+	close(raw)
+	<-done
+	if text := filtered.flush(); text != "" {
+		chunks <- text
+	}
+	reply.text = filtered.String()
+	return reply, err
+}
+
+// conversation is the request as messages. A summary of what was cut and
+// pinned summaries go with the system prompt; turns of the same role in a
+// row are joined, and the messages start with the user's, as the APIs
+// want.
+func (r chatRequest) conversation(dropped, kept []Message) apiRequest {
+	req := apiRequest{model: r.model, params: r.params, session: r.session}
+	system := []string{r.system}
+	if r.summary != "" && len(dropped) > 0 && r.strategy == "summarize" {
+		system = append(system, "Summary of the earlier conversation: "+r.summary)
+	}
+
+	add := func(role, text string) {
+		if n := len(req.messages); n > 0 && req.messages[n-1].Role == role {
+			req.messages[n-1].Content += "\n\n" + text
+			return
+		}
+		if len(req.messages) == 0 && role != "user" {
+			return
+		}
+		req.messages = append(req.messages, apiMessage{Role: role, Content: text})
+	}
+	for _, message := range kept {
+		switch message.Role {
+		case RoleUser:
+			add("user", message.Text)
+		case RoleBot:
+			add("assistant", message.Text)
+		default:
+			system = append(system, message.Text)
+		}
+	}
+	add("user", r.input)
+
+	req.system = strings.TrimSpace(strings.Join(system, "\n\n"))
+	return req
+}
+
+// postJSON sends body to url and returns the response, or an error with
+// the status and whatever message the API put in the body.
+func postJSON(ctx context.Context, url string, header http.Header, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, statusError{code: resp.StatusCode, status: resp.Status, message: apiError(resp.Body)}
+	}
+	return resp, nil
+}
+
+// getJSON fetches url and decodes the response into v, or returns an
+// error the way postJSON does.
+func getJSON(ctx context.Context, url string, header http.Header, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if header != nil {
+		req.Header = header
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return statusError{code: resp.StatusCode, status: resp.Status, message: apiError(resp.Body)}
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// modelList is how OpenAI and Anthropic list models.
+type modelList struct {
+	Data []struct {
+		Id string `json:"id"`
+	} `json:"data"`
+}
+
+func (l modelList) names() []string {
+	names := make([]string, 0, len(l.Data))
+	for _, model := range l.Data {
+		names = append(names, model.Id)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// statusError is a failure a backend answered with an HTTP status, or
+// reported the status of; retries go by its code.
+type statusError struct {
+	code    int
+	status  string // "429 Too Many Requests", or empty when the message says it all
+	message string
+}
+
+func (e statusError) Error() string {
+	if e.status == "" {
+		return e.message
+	}
+	return e.status + ": " + e.message
+}
+
+// apiError digs the message out of an error body, which most APIs shape
+// like {"error": {"message": "..."}}.
+func apiError(body io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(body, 64*1024))
+	var shaped struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(data, &shaped) == nil && len(shaped.Error) > 0 {
+		var detail struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(shaped.Error, &detail) == nil && detail.Message != "" {
+			return detail.Message
+		}
+		var text string
+		if json.Unmarshal(shaped.Error, &text) == nil && text != "" {
+			return text
+		}
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readEvents calls fn with the data of each server-sent event in body
+// until fn says it was the last or the body ends.
+func readEvents(body io.Reader, fn func(data string) (bool, error)) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			if value, ok := strings.CutPrefix(line, "data:"); ok {
+				data = append(data, strings.TrimPrefix(value, " "))
+			}
+			continue
+		}
+		if len(data) == 0 {
+			continue
+		}
+		done, err := fn(strings.Join(data, "\n"))
+		if done || err != nil {
+			return err
+		}
+		data = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		_, err := fn(strings.Join(data, "\n"))
+		return err
+	}
+	return nil
+}
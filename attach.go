@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// ATTACH_MAX_SIZE keeps a stray @ from pasting a binary or a log file into
+// the prompt.
+const ATTACH_MAX_SIZE = 256 << 10
+
+var chipStyle = lipgloss.NewStyle()
+
+// attachment is a file whose contents go out with the next message. Files
+// are attached with /attach or by naming them as @path in the message.
+type attachment struct {
+	path  string
+	size  int64
+	inRef bool // named with @ in the input rather than attached with /attach
+}
+
+func statAttachment(path string) (attachment, error) {
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		return attachment{}, err
+	case info.IsDir():
+		return attachment{}, fmt.Errorf("%s is a directory", path)
+	case info.Size() > ATTACH_MAX_SIZE:
+		return attachment{}, fmt.Errorf("%s is larger than %s", path, formatSize(ATTACH_MAX_SIZE))
+	}
+	return attachment{path: path, size: info.Size()}, nil
+}
+
+// fileRefs are the @path words in text that name a file that can be
+// attached.
+func fileRefs(text string) []attachment {
+	var refs []attachment
+	for _, word := range strings.Fields(text) {
+		path, ok := strings.CutPrefix(word, "@")
+		if !ok || path == "" {
+			continue
+		}
+		if a, err := statAttachment(path); err == nil {
+			a.inRef = true
+			refs = append(refs, a)
+		}
+	}
+	return refs
+}
+
+// attached is what would go out with the input as it is now; each file once.
+func (m model) attached(input string) []attachment {
+	return m.withFiles(fileRefs(input))
+}
+
+// chips is attached for the input in the textarea, with its @paths as
+// lookupRefs last found them, so drawing them does not touch the disk.
+func (m model) chips() []attachment {
+	return m.withFiles(m.refs)
+}
+
+// lookupRefs finds the @paths of the input again when it has changed.
+func (m *model) lookupRefs() {
+	if input := m.textarea.Value(); input != m.refsInput {
+		m.refs, m.refsInput = fileRefs(input), input
+	}
+}
+
+func (m model) withFiles(refs []attachment) []attachment {
+	seen := map[string]bool{}
+	var attached []attachment
+	for _, a := range append(slices.Clone(m.files), refs...) {
+		if path := filepath.Clean(a.path); !seen[path] {
+			seen[path] = true
+			attached = append(attached, a)
+		}
+	}
+	return attached
+}
+
+func (m model) chipsHeight() int {
+	if len(m.chips()) == 0 {
+		return 0
+	}
+	return 1
+}
+
+func (m *model) attach(path string) tea.Cmd {
+	if path == "" {
+		return m.showToast("Usage: /attach <path>")
+	}
+	a, err := statAttachment(path)
+	if err != nil {
+		return m.showToast("Cannot attach: " + err.Error())
+	}
+	if !slices.ContainsFunc(m.files, func(b attachment) bool { return filepath.Clean(b.path) == filepath.Clean(path) }) {
+		m.files = append(m.files, a)
+	}
+	m.resize()
+	return m.showToast("Attached " + path)
+}
+
+// detachLast drops the last chip; an @path in the input loses its @ so it
+// stays in the text as a plain word.
+func (m *model) detachLast() {
+	m.lookupRefs()
+	attached := m.chips()
+	if len(attached) == 0 {
+		return
+	}
+	last := attached[len(attached)-1]
+	if last.inRef {
+		value := m.textarea.Value()
+		if at := strings.LastIndex(value, "@"+last.path); at >= 0 {
+			m.textarea.SetValue(value[:at] + value[at+1:])
+		}
+	} else {
+		m.files = slices.DeleteFunc(m.files, func(a attachment) bool { return a.path == last.path })
+	}
+	m.resize()
+}
+
+// withAttachments appends the files' contents to the prompt.
+func withAttachments(input string, files []attachment) (string, error) {
+	var b strings.Builder
+	b.WriteString(input)
+	for _, file := range files {
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "\n\n--- %s ---\n%s", file.path, strings.TrimRight(string(data), "\n"))
+	}
+	return b.String(), nil
+}
+
+func attachmentNames(files []attachment) []string {
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.path)
+	}
+	return names
+}
+
+// chipsView is the line of attached files over the input.
+func (m model) chipsView(width int) string {
+	attached := m.chips()
+	if len(attached) == 0 {
+		return ""
+	}
+	chips := make([]string, 0, len(attached))
+	for _, a := range attached {
+		chips = append(chips, chipStyle.Render(fmt.Sprintf("[%s %s]", filepath.Base(a.path), formatSize(a.size))))
+	}
+	line := strings.Join(chips, " ") + "  " + hint(m.keys.Chat.Detach)
+	return ansi.Truncate(line, width, "…")
+}
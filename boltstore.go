@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const BOLT_DB_NAME = "chat.bolt"
+
+var (
+	sessionsBucket = []byte("sessions")
+	messagesBucket = []byte("messages")
+	versionsBucket = []byte("versions")
+)
+
+// BoltStore keeps session metadata (timestamps) and message payloads in
+// separate buckets of a bbolt file, keyed by big-endian session id.
+// Overwritten records are kept as encoded records in versions/<id>, the
+// last KEEP_VERSIONS with the current one.
+type BoltStore struct {
+	stdOut chan string
+	path   string
+	db     *bolt.DB
+}
+
+func NewBoltStore(stdOut chan string, path string) *BoltStore {
+	return &BoltStore{
+		stdOut: stdOut,
+		path:   path,
+	}
+}
+
+func boltKey(id uint32) []byte {
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, id)
+	return key
+}
+
+func (s *BoltStore) Check() error {
+	if s.db == nil {
+		return errors.New("bolt store is not initialized")
+	}
+	return s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(sessionsBucket) == nil || tx.Bucket(messagesBucket) == nil {
+			return errors.New("bolt store is missing its buckets")
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) Initialize() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	_, statErr := os.Stat(s.path)
+	created := os.IsNotExist(statErr)
+
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	s.db = db
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{sessionsBucket, messagesBucket, versionsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !created {
+		go func() {
+			s.stdOut <- "Database already exists"
+		}()
+		return nil
+	}
+
+	// First run on bolt: carry over whatever the flat file had.
+	legacy := &Storage{stdOut: s.stdOut}
+	if err := legacy.Check(); err != nil {
+		go func() {
+			s.stdOut <- "Database created successfully"
+		}()
+		return nil
+	}
+	if err := legacy.Initialize(); err != nil {
+		return err
+	}
+
+	count, err := copyRecords(s, legacy)
+	if err != nil {
+		return fmt.Errorf("converting %s: %w", legacy.dbPath(), err)
+	}
+	go func() {
+		s.stdOut <- fmt.Sprintf("Converted %d records from %s", count, legacy.dbPath())
+	}()
+	return nil
+}
+
+func (s *BoltStore) Store(id uint32, content Content) (uint32, error) {
+	if s.db == nil {
+		return 0, errors.New("bolt store is not initialized")
+	}
+	if err := checkRecordSize(content); err != nil {
+		return 0, err
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket(sessionsBucket)
+		if id == 0 {
+			seq, err := sessions.NextSequence()
+			if err != nil {
+				return err
+			}
+			id = uint32(seq)
+		} else if uint64(id) > sessions.Sequence() {
+			if err := sessions.SetSequence(uint64(id)); err != nil {
+				return err
+			}
+		}
+
+		if previous, ok := readBoltRecord(tx, id); ok {
+			versions, err := tx.Bucket(versionsBucket).CreateBucketIfNotExists(boltKey(id))
+			if err != nil {
+				return err
+			}
+			seq, err := versions.NextSequence()
+			if err != nil {
+				return err
+			}
+			if err := versions.Put(boltKey(uint32(seq)), encodeContent(id, previous)); err != nil {
+				return err
+			}
+			if err := pruneBoltVersions(versions); err != nil {
+				return err
+			}
+		}
+
+		meta := make([]byte, 24)
+		binary.BigEndian.PutUint64(meta[:8], uint64(content.CreatedAt))
+		binary.BigEndian.PutUint64(meta[8:16], uint64(content.UpdatedAt))
+		binary.BigEndian.PutUint64(meta[16:24], uint64(content.DeletedAt))
+		if err := sessions.Put(boltKey(id), meta); err != nil {
+			return err
+		}
+		return tx.Bucket(messagesBucket).Put(boltKey(id), content.Content)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	go func() {
+		s.stdOut <- fmt.Sprintf("Stored message with ID %d", id)
+	}()
+
+	return id, nil
+}
+
+// pruneBoltVersions deletes the oldest versions in bucket, leaving room for
+// KEEP_VERSIONS with the current one.
+func pruneBoltVersions(bucket *bolt.Bucket) error {
+	var keys [][]byte
+	bucket.ForEach(func(k, _ []byte) error {
+		keys = append(keys, append([]byte(nil), k...))
+		return nil
+	})
+	for _, key := range keys[:max(len(keys)-(KEEP_VERSIONS-1), 0)] {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *BoltStore) Update(id uint32, content Content) error {
+	return updateRecord(s, id, content)
+}
+
+// readBoltRecord copies a record out of tx; bolt memory is only valid
+// until the transaction ends.
+func readBoltRecord(tx *bolt.Tx, id uint32) (Content, bool) {
+	meta := tx.Bucket(sessionsBucket).Get(boltKey(id))
+	if meta == nil {
+		return Content{}, false
+	}
+	payload := tx.Bucket(messagesBucket).Get(boltKey(id))
+
+	content := Content{
+		Id:        id,
+		CreatedAt: int64(binary.BigEndian.Uint64(meta[:8])),
+		UpdatedAt: int64(binary.BigEndian.Uint64(meta[8:16])),
+		Length:    uint32(len(payload)),
+		Content:   append([]byte(nil), payload...),
+	}
+	// sessions written before soft delete only had the two timestamps
+	if len(meta) >= 24 {
+		content.DeletedAt = int64(binary.BigEndian.Uint64(meta[16:24]))
+	}
+	return content, true
+}
+
+func (s *BoltStore) Get(id uint32) (Content, error) {
+	if s.db == nil {
+		return Content{}, errors.New("bolt store is not initialized")
+	}
+
+	var content Content
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var ok bool
+		if content, ok = readBoltRecord(tx, id); !ok {
+			return ErrNotFound
+		}
+		return nil
+	})
+	return content, err
+}
+
+func (s *BoltStore) GetVersions(id uint32) ([]Content, error) {
+	if s.db == nil {
+		return nil, errors.New("bolt store is not initialized")
+	}
+
+	var versions []Content
+	err := s.db.View(func(tx *bolt.Tx) error {
+		current, ok := readBoltRecord(tx, id)
+		if !ok {
+			return ErrNotFound
+		}
+
+		if bucket := tx.Bucket(versionsBucket).Bucket(boltKey(id)); bucket != nil {
+			err := bucket.ForEach(func(_, v []byte) error {
+				content, err := decodeContent(v)
+				if err != nil {
+					return err
+				}
+				versions = append(versions, content)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		versions = append(versions, current)
+		return nil
+	})
+	return versions, err
+}
+
+func (s *BoltStore) GetIds() ([]uint32, error) {
+	if s.db == nil {
+		return nil, errors.New("bolt store is not initialized")
+	}
+
+	ids := []uint32{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).ForEach(func(k, _ []byte) error {
+			ids = append(ids, binary.BigEndian.Uint32(k))
+			return nil
+		})
+	})
+	return ids, err
+}
+
+func (s *BoltStore) Delete(id uint32) error {
+	return trashRecord(s, id)
+}
+
+func (s *BoltStore) Restore(id uint32) error {
+	return restoreRecord(s, id)
+}
+
+func (s *BoltStore) List() ([]Content, error) {
+	return listRecords(s)
+}
+
+func (s *BoltStore) ListTrash() ([]Content, error) {
+	return listTrash(s)
+}
+
+func (s *BoltStore) PurgeTrash(grace time.Duration) (int, error) {
+	expired, err := expiredTrash(s, grace)
+	if err != nil || len(expired) == 0 {
+		return 0, err
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		for _, id := range expired {
+			if err := tx.Bucket(sessionsBucket).Delete(boltKey(id)); err != nil {
+				return err
+			}
+			if err := tx.Bucket(messagesBucket).Delete(boltKey(id)); err != nil {
+				return err
+			}
+			versions := tx.Bucket(versionsBucket)
+			if versions.Bucket(boltKey(id)) != nil {
+				if err := versions.DeleteBucket(boltKey(id)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	go func() {
+		s.stdOut <- fmt.Sprintf("Purged %d chats from the trash", len(expired))
+	}()
+	return len(expired), nil
+}
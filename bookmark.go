@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Bookmarks mark messages worth coming back to (b in select mode). They
+// are stored with the session; alt+b lists them from every session.
+
+func (m *model) toggleBookmark(i int) tea.Cmd {
+	m.messages[i].Bookmarked = !m.messages[i].Bookmarked
+	m.changed()
+	if m.autosave && m.currentId != 0 {
+		m.save()
+	}
+	m.showSelected()
+	if m.messages[i].Bookmarked {
+		return m.showToast("Bookmarked")
+	}
+	return m.showToast("Bookmark removed")
+}
+
+func (m model) openBookmarks() (model, tea.Cmd) {
+	sessions, err := listSessions(m.storage, true)
+	if err != nil {
+		return m, m.showToast("Cannot list sessions: " + err.Error())
+	}
+
+	m.bookmarks = nil
+	var items []menuItem
+	add := func(id uint32, name string, messages []Message) {
+		for i, message := range messages {
+			if !message.Bookmarked {
+				continue
+			}
+			entry := paletteEntry{session: id, index: i, title: message.Role.Label() + ": " + strings.Join(strings.Fields(message.Text), " "), detail: name}
+			m.bookmarks = append(m.bookmarks, entry)
+			items = append(items, menuItem{title: entry.title, detail: entry.detail})
+		}
+	}
+	// the chat on screen may be ahead of what is stored
+	add(m.currentId, m.sessionTitle(), m.messages)
+	for _, session := range sessions {
+		if session.Id != m.currentId {
+			add(session.Id, session.Name(), session.Messages)
+		}
+	}
+
+	m.menu = menu{title: "Bookmarks", hint: "enter jump · esc close · b in select mode adds one", items: items}
+	m.mode = modeBookmarks
+	m.textarea.Blur()
+	return m, nil
+}
+
+func (m model) updateBookmarks(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q", "alt+b":
+		return m.closeOverlay(), nil
+	case "up", "k":
+		m.menu.up()
+	case "down", "j":
+		m.menu.down()
+	case "enter":
+		if m.menu.cursor >= len(m.bookmarks) {
+			return m, nil
+		}
+		return m.closeOverlay().goTo(m.bookmarks[m.menu.cursor])
+	}
+	return m, nil
+}
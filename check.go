@@ -0,0 +1,107 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// At launch, and when /provider switches, relay makes sure the chat's
+// provider can answer before anything is sent to it: the CLI is
+// installed, or the API is there and takes the key. What stands in the
+// way is said along with what to do about it.
+
+// CHECK_TIMEOUT bounds asking an API whether it is there.
+const CHECK_TIMEOUT = 5 * time.Second
+
+// setupHints say how to get a provider working; those from chat.commands
+// are fixed in the config.
+var setupHints = map[string]string{
+	"claude":    "install it with npm install -g @anthropic-ai/claude-code",
+	CLAUDE_CODE: "install it with npm install -g @anthropic-ai/claude-code",
+	"gemini":    "install it with npm install -g @google/gemini-cli",
+	"openai":    "check OPENAI_API_KEY and OPENAI_BASE_URL",
+	"anthropic": "check ANTHROPIC_API_KEY and ANTHROPIC_BASE_URL",
+	"ollama":    "start it with ollama serve or point OLLAMA_HOST at it",
+}
+
+// apiPrograms are the API providers that run a CLI underneath.
+var apiPrograms = map[string]string{
+	CLAUDE_CODE: "claude",
+}
+
+type backendCheckMsg struct {
+	provider string
+	problem  string
+}
+
+// backendProblem says what keeps provider from answering and how to fix
+// it, or "" when nothing does as far as relay can tell.
+func backendProblem(ctx context.Context, provider string) string {
+	if !knownProvider(provider) {
+		return fmt.Sprintf("Unknown provider %s; available: %s", provider, strings.Join(providerNames(), ", "))
+	}
+
+	var problem error
+	if build, ok := providers[provider]; ok {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		// the command is never started; building it looks the program up
+		cmd := build(ctx, "", "", Params{})
+		program := ""
+		if len(cmd.Args) > 0 {
+			program = cmd.Args[0]
+		}
+		problem = notFound(program, cmd.Err)
+	} else if program, ok := apiPrograms[provider]; ok {
+		_, err := exec.LookPath(program)
+		problem = notFound(program, err)
+	} else if list, ok := modelListers[provider]; ok {
+		ctx, cancel := context.WithTimeout(ctx, CHECK_TIMEOUT)
+		defer cancel()
+		if _, err := list(ctx); err != nil {
+			// the URL of a request that failed is in the hint already
+			if urlErr := (*url.Error)(nil); errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			problem = fmt.Errorf("%s: %w", provider, err)
+		}
+	}
+	if problem == nil {
+		return ""
+	}
+	hint := cmp.Or(setupHints[provider], "check chat.commands."+provider+" in the config")
+	return fmt.Sprintf("%s; %s, or pick another provider with /provider", problem, hint)
+}
+
+func notFound(program string, err error) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("%s not found in PATH", program)
+	}
+	return err
+}
+
+// checkBackend looks for problems with the chat's provider in the
+// background.
+func (m model) checkBackend() tea.Cmd {
+	provider, ctx := m.meta.Provider, m.ctx
+	return func() tea.Msg {
+		return backendCheckMsg{provider: provider, problem: backendProblem(ctx, provider)}
+	}
+}
+
+// applyBackendCheck shows the problem as a notice, which leaves the chat
+// as it was, unless the chat has moved on to another provider since.
+func (m *model) applyBackendCheck(msg backendCheckMsg) tea.Cmd {
+	if msg.problem == "" || msg.provider != m.meta.Provider {
+		return nil
+	}
+	return m.notify(msg.problem)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Checkpoints are ordinary records whose transcript carries a Label and the
+// id of the chat they were taken from.
+
+func (m *model) saveCheckpoint(name string) {
+	if m.currentId == 0 && m.save() != nil {
+		return
+	}
+
+	now := time.Now().Unix()
+	payload := encodeTranscript(transcript{
+		Messages: append([]Message(nil), m.messages...),
+		Label:    name,
+		Parent:   m.currentId,
+		Offset:   m.viewport.YOffset,
+	})
+	content := Content{
+		CreatedAt: now,
+		UpdatedAt: now,
+		Length:    uint32(len(payload)),
+		Content:   payload,
+	}
+	if _, err := m.storage.Store(0, content); err != nil {
+		m.addMessage(RoleSystem, "Error saving checkpoint: "+err.Error())
+		return
+	}
+	m.addMessage(RoleSystem, fmt.Sprintf("Checkpoint %q saved", name))
+}
+
+func listCheckpoints(storage Store, parent uint32) ([]menuItem, error) {
+	records, err := storage.List()
+	if err != nil {
+		return nil, err
+	}
+
+	items := []menuItem{}
+	for _, content := range records {
+		t := decodeTranscript(content.Content)
+		if t.Label == "" || t.Parent != parent {
+			continue
+		}
+		items = append(items, menuItem{
+			title:  t.Label,
+			detail: fmt.Sprintf("%s, %d messages", time.Unix(content.CreatedAt, 0).Format("2006-01-02 15:04"), len(t.Messages)),
+			id:     content.Id,
+		})
+	}
+	return items, nil
+}
+
+func (m model) openCheckpoints() (model, tea.Cmd) {
+	if m.currentId == 0 {
+		m.addMessage(RoleSystem, "No checkpoints yet: this chat has not been saved")
+		return m, nil
+	}
+
+	items, err := listCheckpoints(m.storage, m.currentId)
+	if err != nil {
+		m.addMessage(RoleSystem, "Error listing checkpoints: "+err.Error())
+		return m, nil
+	}
+
+	m.menu = menu{title: "Restore checkpoint", hint: "enter restore · esc close", items: items}
+	m.mode = modeCheckpoints
+	m.textarea.Blur()
+	return m, nil
+}
+
+func (m model) updateCheckpoints(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "alt+r":
+		return m.closeOverlay(), nil
+	case "up", "k":
+		m.menu.up()
+	case "down", "j":
+		m.menu.down()
+	case "enter":
+		item, ok := m.menu.selected()
+		m = m.closeOverlay()
+		if !ok {
+			return m, nil
+		}
+
+		content, err := m.storage.Get(item.id)
+		if err != nil {
+			m.addMessage(RoleSystem, "Error loading checkpoint: "+err.Error())
+			return m, nil
+		}
+		m.restoreCheckpoint(decodeTranscript(content.Content), item.title)
+	}
+	return m, nil
+}
+
+// restoreCheckpoint puts the chat back to where it was at the checkpoint,
+// scrolled as it was. Whatever pointed into the messages it replaces is
+// dropped with them.
+func (m *model) restoreCheckpoint(t transcript, name string) {
+	m.dropResponse()
+	m.messages = t.Messages
+	m.editing = 0
+	m.expanded = nil
+	m.compare.mark = 0
+	m.meta.Summary, m.meta.Summarized = "", 0
+	m.keepResume("")
+	m.addMessage(RoleSystem, fmt.Sprintf("Restored checkpoint %q", name))
+	m.viewport.SetYOffset(t.Offset)
+}
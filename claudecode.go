@@ -0,0 +1,102 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// claude-code runs the claude CLI with --output-format json and resumes
+// its session on the next message, so Claude keeps the conversation
+// itself instead of getting the history every time. The answer comes all
+// at once rather than streamed.
+
+const CLAUDE_CODE = "claude-code"
+
+// claudeResult is what claude -p --output-format json prints.
+type claudeResult struct {
+	Result    string         `json:"result"`
+	IsError   bool           `json:"is_error"`
+	Subtype   string         `json:"subtype"` // "success" or why it stopped, like "error_max_turns"
+	SessionId string         `json:"session_id"`
+	Usage     anthropicUsage `json:"usage"`
+	Cost      float64        `json:"total_cost_usd"`
+}
+
+func claudeCode(ctx context.Context, req apiRequest, chunks chan<- string) (apiReply, error) {
+	args := []string{"-p", "--output-format", "json"}
+	if req.model != "" {
+		args = append(args, "--model", req.model)
+	}
+	if req.session != "" {
+		args = append(args, "--resume", req.session)
+	}
+	if req.system != "" {
+		args = append(args, "--append-system-prompt", req.system)
+	}
+	cmd := exec.CommandContext(ctx, "claude", args...)
+	// on stdin the prompt is not cut by the argument limit
+	cmd.Stdin = strings.NewReader(claudePrompt(req.messages))
+
+	out, err := runCommand(cmd, cliAdapter{}, nil)
+	var result claudeResult
+	if jsonErr := json.Unmarshal([]byte(out), &result); jsonErr != nil {
+		if err == nil {
+			err = fmt.Errorf("unexpected output from claude: %s", strings.TrimSpace(out))
+		}
+		return apiReply{}, err
+	}
+	if result.IsError || err != nil {
+		reason := cmp.Or(result.Result, strings.ReplaceAll(strings.TrimPrefix(result.Subtype, "error_"), "_", " "), "failed")
+		return apiReply{}, errors.New("claude: " + reason)
+	}
+	if chunks != nil {
+		chunks <- result.Result
+	}
+	usage := Usage{Prompt: result.Usage.prompt(), Completion: result.Usage.OutputTokens, Cost: result.Cost}
+	return apiReply{text: result.Result, session: result.SessionId, usage: usage}, nil
+}
+
+// claudePrompt is the conversation as one prompt, for a new session; when
+// one is resumed there is only the new input.
+func claudePrompt(messages []apiMessage) string {
+	if len(messages) == 1 {
+		return messages[0].Content
+	}
+	var b strings.Builder
+	for _, message := range messages[:len(messages)-1] {
+		label := RoleUser.Label()
+		if message.Role == "assistant" {
+			label = RoleBot.Label()
+		}
+		b.WriteString(label + ": " + message.Content + "\n")
+	}
+	b.WriteString("\n" + RoleUser.Label() + ": " + messages[len(messages)-1].Content)
+	return b.String()
+}
+
+// keepResume remembers the backend's id for the conversation, or forgets
+// it when the backend gave none.
+func (m *model) keepResume(session string) {
+	if session == "" {
+		m.meta.Resume, m.meta.ResumeAt = "", 0
+		return
+	}
+	m.meta.Resume, m.meta.ResumeAt = session, exchanged(m.messages)
+}
+
+// exchanged counts the user and bot messages, which are what the backend
+// has seen; notes in between do not change it.
+func exchanged(messages []Message) int {
+	count := 0
+	for _, message := range messages {
+		if message.Role == RoleUser || message.Role == RoleBot {
+			count++
+		}
+	}
+	return count
+}
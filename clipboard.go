@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// copyToClipboard sets the system clipboard and also sends the text as an
+// OSC 52 sequence, which reaches the local clipboard over SSH and inside
+// tmux where there is no native one. It reports which route was taken.
+func copyToClipboard(text string) string {
+	seq := osc52.New(text)
+	if os.Getenv("TMUX") != "" {
+		seq = seq.Tmux()
+	} else if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		seq = seq.Screen()
+	}
+	seq.WriteTo(os.Stderr)
+
+	if err := clipboard.WriteAll(text); err != nil {
+		return "OSC 52"
+	}
+	return "clipboard"
+}
+
+type codeBlock struct {
+	lang string
+	code string
+}
+
+// codeBlocks returns the fenced code blocks of a message, in order. An
+// unclosed fence runs to the end of the message.
+func codeBlocks(text string) []codeBlock {
+	var blocks []codeBlock
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		info, ok := strings.CutPrefix(strings.TrimSpace(lines[i]), "```")
+		if !ok {
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && strings.TrimSpace(lines[end]) != "```" {
+			end++
+		}
+		lang, _, _ := strings.Cut(strings.TrimSpace(info), " ")
+		blocks = append(blocks, codeBlock{lang: lang, code: strings.Join(lines[i+1:min(end, len(lines))], "\n")})
+		i = end
+	}
+	return blocks
+}
+
+func (m model) lastResponse() (int, bool) {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Role == RoleBot {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// copyCodeBlock copies the next code block of the latest response; pressing
+// it again moves on to the following block, wrapping around.
+func (m *model) copyCodeBlock() tea.Cmd {
+	at, ok := m.lastResponse()
+	if !ok {
+		return m.showToast("No response to copy from")
+	}
+	blocks := codeBlocks(m.messages[at].Text)
+	if len(blocks) == 0 {
+		return m.showToast("The last response has no code blocks")
+	}
+
+	if m.codeFrom != at {
+		m.codeFrom, m.codeIndex = at, 0
+	} else {
+		m.codeIndex = (m.codeIndex + 1) % len(blocks)
+	}
+	block := blocks[m.codeIndex]
+	lang := block.lang
+	if lang == "" {
+		lang = "code"
+	}
+	via := copyToClipboard(block.code)
+	return m.showToast(fmt.Sprintf("Copied block %d/%d (%s, %d lines) via %s", m.codeIndex+1, len(blocks), lang, strings.Count(block.code, "\n")+1, via))
+}
+
+// copyResponse copies the latest bot message as plain text.
+func (m *model) copyResponse() tea.Cmd {
+	at, ok := m.lastResponse()
+	if !ok {
+		return m.showToast("No response to copy")
+	}
+	via := copyToClipboard(ansi.Strip(m.messages[at].Text))
+	return m.showToast("Copied the last response via " + via)
+}
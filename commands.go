@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type slashCommand struct {
+	usage string
+	help  string
+}
+
+func (c slashCommand) name() string {
+	name, _, _ := strings.Cut(strings.TrimPrefix(c.usage, "/"), " ")
+	return name
+}
+
+// slashCommands is what the help overlay lists.
+var slashCommands = []slashCommand{
+	{"/new", "start a new chat"},
+	{"/open <id>", "open a saved session"},
+	{"/save", "save the chat"},
+	{"/clear", "remove every message from the chat"},
+	{"/provider [name]", "show or switch the backend of this chat"},
+	{"/model [name|default]", "show or set the model of this chat"},
+	{"/models", "list the provider's models and pick one"},
+	{"/system [prompt|clear]", "show or set the system prompt of this chat"},
+	{"/params [name value]", "show or set temperature, top_p and max_tokens for this chat"},
+	{"/export", "export as Markdown"},
+	{"/attach <path>", "send a file with the next message"},
+	{"/summarize [collapse]", "summarize the chat"},
+	{"/tag <tag>", "tag the session"},
+	{"/untag <tag>", "remove a tag"},
+	{"/help", "show this help"},
+}
+
+// runSlashCommand handles input starting with "/" instead of sending it
+// to the backend.
+func (m model) runSlashCommand(input string) (model, tea.Cmd) {
+	name, args, _ := strings.Cut(strings.TrimPrefix(input, "/"), " ")
+	args = strings.TrimSpace(args)
+
+	switch name {
+	case "new":
+		m.newSession()
+		return m, nil
+	case "open":
+		id, err := strconv.ParseUint(strings.TrimPrefix(args, "#"), 10, 32)
+		if err != nil {
+			m.addMessage(RoleSystem, "Usage: /open <id>")
+			return m, nil
+		}
+		if err := m.loadSession(uint32(id)); err != nil {
+			m.addMessage(RoleSystem, "Error loading session: "+err.Error())
+		}
+		return m, nil
+	case "save":
+		if m.save() != nil {
+			// the notice with the reason follows; plain mode only has this
+			return m, m.showToast("Could not save the chat")
+		}
+		return m, m.showToast(fmt.Sprintf("Saved as #%d", m.currentId))
+	case "clear":
+		if len(m.messages) == 0 {
+			return m, nil
+		}
+		m.deleteMessages(0, len(m.messages))
+		m.viewport.SetContent("Chat cleared. Type a message below.")
+		return m, nil
+	case "provider":
+		if args == "" {
+			return m, m.showToast("Provider: " + backendName(m.meta.Provider, m.meta.Model) + " · available: " + strings.Join(providerNames(), ", "))
+		}
+		if !knownProvider(args) {
+			return m, m.showToast(fmt.Sprintf("Unknown provider %s; available: %s", args, strings.Join(providerNames(), ", ")))
+		}
+		// the history goes along, so the new backend picks up the conversation
+		m.meta.Provider, m.meta.Model = args, m.chat.DefaultModel(args)
+		m.changed()
+		// the session id is the old backend's
+		m.keepResume("")
+		return m, tea.Batch(m.showToast("Provider: "+backendName(m.meta.Provider, m.meta.Model)), m.checkBackend())
+	case "model":
+		if args == "" {
+			return m, m.showToast("Model: " + backendName(m.meta.Provider, m.meta.Model))
+		}
+		m.meta.Model = args
+		if args == "default" {
+			m.meta.Model = ""
+		}
+		m.changed()
+		return m, m.showToast("Model: " + backendName(m.meta.Provider, m.meta.Model))
+	case "models":
+		return m.listModels()
+	case "params":
+		return m, m.showToast(m.params(args))
+	case "system":
+		m.setSystem(args)
+		return m, nil
+	case "export":
+		m.exportSession()
+		return m, nil
+	case "attach":
+		return m, m.attach(args)
+	case "summarize":
+		return m.summarize(args == "collapse")
+	case "tag":
+		m.tagSession(args, false)
+		return m, nil
+	case "untag":
+		m.tagSession(args, true)
+		return m, nil
+	case "help":
+		return m.openHelp()
+	}
+
+	text := "Unknown command /" + name + "; /help lists them"
+	if like := similarCommand(name); like != "" {
+		text = fmt.Sprintf("Unknown command /%s; did you mean /%s?", name, like)
+	}
+	m.addMessage(RoleSystem, text)
+	return m, nil
+}
+
+// similarCommand guesses what a mistyped command was meant to be: one that
+// starts the same way, or the closest by edit distance.
+func similarCommand(name string) string {
+	best, bestDistance := "", 3
+	for _, c := range slashCommands {
+		if name != "" && strings.HasPrefix(c.name(), name) {
+			return c.name()
+		}
+		if d := editDistance(name, c.name()); d < bestDistance {
+			best, bestDistance = c.name(), d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,35 @@
+package main
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// The compact layout drops the margins around the app and the border and
+// padding around the transcript, so small windows such as tmux panes get
+// more lines of the chat. The title and toasts move to the status bar.
+
+func setCompact(on bool) {
+	if on {
+		appStyle = appStyle.Margin(0)
+		viewportStyle = viewportStyle.
+			BorderTop(false).BorderBottom(false).BorderLeft(false).BorderRight(false).
+			Padding(0)
+		return
+	}
+	appStyle = appStyle.Margin(1, 2)
+	viewportStyle = viewportStyle.
+		BorderTop(true).BorderBottom(true).BorderLeft(true).BorderRight(true).
+		Padding(1, 2)
+}
+
+func (m model) toggleCompact() (model, tea.Cmd) {
+	following := m.viewport.AtBottom()
+	m.compact = !m.compact
+	setCompact(m.compact)
+	m.resize()
+	if following {
+		m.viewport.GotoBottom()
+	}
+	if m.compact {
+		return m, m.showToast("Compact layout")
+	}
+	return m, m.showToast("Full layout")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Compare puts two responses side by side: c on one bot message in select
+// mode marks it, c on another opens the view. Both columns scroll together.
+type compare struct {
+	mark   int // marked message, counted from 1; 0 when none
+	left   int
+	right  int
+	scroll int
+}
+
+func (m model) markCompare() (model, tea.Cmd) {
+	if m.messages[m.selected].Role != RoleBot {
+		return m, m.showToast("Only responses can be compared")
+	}
+	mark := m.compare.mark - 1
+	if mark < 0 || mark >= len(m.messages) || mark == m.selected {
+		m.compare.mark = m.selected + 1
+		return m, m.showToast("Marked; press c on another response to compare")
+	}
+
+	m.compare = compare{left: min(mark, m.selected), right: max(mark, m.selected)}
+	m.mode = modeCompare
+	return m, nil
+}
+
+func (m model) updateCompare(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	keys := m.keys.Normal
+	page := max(m.viewport.Height-2, 1)
+	switch {
+	case msg.String() == "esc" || msg.String() == "q":
+		m.mode = modeSelect
+		m.showSelected()
+		return m, nil
+	case key.Matches(msg, keys.Down):
+		m.compare.scroll++
+	case key.Matches(msg, keys.Up):
+		m.compare.scroll--
+	case key.Matches(msg, keys.PageDown, keys.HalfDown):
+		m.compare.scroll += page
+	case key.Matches(msg, keys.PageUp, keys.HalfUp):
+		m.compare.scroll -= page
+	case key.Matches(msg, keys.Top):
+		m.compare.scroll = 0
+	case key.Matches(msg, keys.Bottom):
+		m.compare.scroll = len(m.compareLines(m.viewport.Width))
+	}
+	m.compare.scroll = min(max(m.compare.scroll, 0), max(len(m.compareLines(m.viewport.Width))-m.viewport.Height+2, 0))
+	return m, nil
+}
+
+// compareColumn renders one response wrapped to width, under a header
+// saying which message it is.
+func (m model) compareColumn(i, width int) []string {
+	message := m.messages[i]
+	header := fmt.Sprintf("Message %d", i+1)
+	if message.Latency > 0 {
+		header += fmt.Sprintf(" · %.1fs", float64(message.Latency)/1000)
+	}
+	body := ansi.Wrap(highlightCode(renderTables(message.Text, width)), width, "")
+	return append([]string{menuTitleStyle.Render(header), ""}, strings.Split(body, "\n")...)
+}
+
+// compareLines joins the two columns row by row, padding the shorter one.
+func (m model) compareLines(width int) []string {
+	column := max((width-3)/2, 1)
+	left := m.compareColumn(m.compare.left, column)
+	right := m.compareColumn(m.compare.right, column)
+	divider := menuDetailStyle.Render(" │ ")
+
+	lines := make([]string, max(len(left), len(right)))
+	for i := range lines {
+		var l, r string
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		lines[i] = l + strings.Repeat(" ", max(column-lipgloss.Width(l), 0)) + divider + r
+	}
+	return lines
+}
+
+func (m model) compareView(width, height int) string {
+	lines := m.compareLines(width)
+	visible := max(height-2, 1)
+	scroll := min(m.compare.scroll, max(len(lines)-visible, 0))
+	lines = lines[scroll:min(scroll+visible, len(lines))]
+
+	header := []string{menuDetailStyle.Render("j/k scroll · esc back"), ""}
+	return strings.Join(append(header, lines...), "\n")
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// COMPLETION_ROWS is how many completions show at once, between the status
+// bar and the input.
+const COMPLETION_ROWS = 5
+
+// completion lists what the end of the input may be completed to: slash
+// command names and their arguments, and file paths after @ or /attach.
+// Tab takes the highlighted one, esc hides the list until the input
+// changes.
+type completion struct {
+	menu      menu // titles replace the input from start on
+	start     int
+	input     string // input the list was built for
+	dismissed bool
+}
+
+func (c completion) visible() bool {
+	return !c.dismissed && len(c.menu.items) > 0
+}
+
+// completeInput rebuilds the list when the input has changed and resizes
+// the transcript to make room for it.
+func (m *model) completeInput() {
+	input := m.textarea.Value()
+	if input == m.complete.input {
+		return
+	}
+	rows := m.completionHeight()
+	start, items := m.completions(input)
+	m.complete = completion{menu: menu{items: items}, start: start, input: input}
+	if m.completionHeight() != rows {
+		m.resize()
+	}
+}
+
+func (m model) completionHeight() int {
+	if !m.complete.visible() {
+		return 0
+	}
+	return min(len(m.complete.menu.items), COMPLETION_ROWS)
+}
+
+// completions returns where the word being completed starts and what it
+// may become.
+func (m model) completions(input string) (int, []menuItem) {
+	word := strings.LastIndexAny(input, " \t\n") + 1
+	if strings.HasPrefix(input[word:], "@") {
+		return word + 1, pathCompletions(input[word+1:])
+	}
+	if !strings.HasPrefix(input, "/") || strings.Contains(input, "\n") {
+		return 0, nil
+	}
+
+	name, arg, hasArg := strings.Cut(strings.TrimPrefix(input, "/"), " ")
+	if !hasArg {
+		var items []menuItem
+		for _, c := range slashCommands {
+			if strings.HasPrefix(c.name(), name) {
+				full, detail := "/"+c.name(), c.help
+				if _, args, ok := strings.Cut(c.usage, " "); ok {
+					full, detail = full+" ", args+" · "+c.help
+				}
+				items = append(items, menuItem{title: full, detail: detail})
+			}
+		}
+		return 0, items
+	}
+
+	start := len(input) - len(arg)
+	var options []menuItem
+	switch name {
+	case "attach":
+		return start, pathCompletions(arg)
+	case "open":
+		sessions, err := listSessions(m.storage, false)
+		if err != nil {
+			return start, nil
+		}
+		for _, session := range sessions {
+			id := fmt.Sprint(session.Id)
+			if strings.HasPrefix(id, arg) || strings.Contains(strings.ToLower(session.Name()), strings.ToLower(arg)) {
+				options = append(options, menuItem{title: id, detail: session.Name()})
+			}
+		}
+		return start, options
+	case "provider":
+		for _, name := range providerNames() {
+			options = append(options, menuItem{title: name, detail: m.chat.DefaultModel(name)})
+		}
+	case "model":
+		for _, model := range m.knownModels() {
+			options = append(options, menuItem{title: model, detail: m.meta.Provider})
+		}
+		options = append(options, menuItem{title: "default", detail: "whatever " + m.meta.Provider + " picks"})
+	case "tag":
+		sessions, _ := listSessions(m.storage, false)
+		for _, tag := range knownTags(sessions) {
+			options = append(options, menuItem{title: tag})
+		}
+	case "untag":
+		for _, tag := range m.meta.Tags {
+			options = append(options, menuItem{title: tag})
+		}
+	case "params":
+		if !strings.Contains(arg, " ") {
+			for _, name := range paramNames {
+				options = append(options, menuItem{title: name + " ", detail: cmp.Or(m.meta.Params.over(m.chat.Params).get(name), "default")})
+			}
+		}
+	case "summarize":
+		options = []menuItem{{title: "collapse", detail: "fold the summarized messages away"}}
+	}
+
+	var items []menuItem
+	for _, option := range options {
+		if strings.HasPrefix(option.title, arg) && option.title != arg {
+			items = append(items, option)
+		}
+	}
+	return start, items
+}
+
+// pathCompletions lists the files and directories, relative to the working
+// directory, whose path starts with prefix. Hidden ones only show once a
+// dot is typed.
+func pathCompletions(prefix string) []menuItem {
+	dir, base := filepath.Split(prefix)
+	entries, err := os.ReadDir(cmp.Or(dir, "."))
+	if err != nil {
+		return nil
+	}
+
+	var items []menuItem
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		item := menuItem{title: dir + name}
+		if entry.IsDir() {
+			item.title += string(filepath.Separator)
+			item.detail = "dir"
+		} else if info, err := entry.Info(); err == nil {
+			item.detail = formatSize(info.Size())
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n < 1<<10:
+		return fmt.Sprintf("%d B", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
+
+// knownModels are the current provider's usual models and any model named
+// in the config or templates.
+func (m model) knownModels() []string {
+	models := slices.Clone(providerModels[m.meta.Provider])
+	models = append(models, m.chat.DefaultModel(m.meta.Provider))
+	for _, tmpl := range m.templates {
+		if tmpl.Provider == m.meta.Provider {
+			models = append(models, tmpl.Model)
+		}
+	}
+	slices.Sort(models)
+	return slices.DeleteFunc(slices.Compact(models), func(model string) bool { return model == "" })
+}
+
+// acceptCompletion puts the highlighted completion in the input.
+func (m *model) acceptCompletion() {
+	item, ok := m.complete.menu.selected()
+	if !ok {
+		return
+	}
+	m.textarea.SetValue(m.complete.input[:m.complete.start] + item.title)
+	m.completeInput()
+}
+
+func (m *model) dismissCompletion() {
+	m.complete.dismissed = true
+	m.resize()
+}
+
+func (m model) completionView(width int) string {
+	mn := m.complete.menu
+	start := max(mn.cursor-COMPLETION_ROWS+1, 0)
+	end := min(start+COMPLETION_ROWS, len(mn.items))
+
+	lines := make([]string, 0, end-start)
+	for i := start; i < end; i++ {
+		item := mn.items[i]
+		line := "  " + item.title
+		if i == mn.cursor {
+			line = menuSelectedStyle.Render("> " + item.title)
+		}
+		if item.detail != "" {
+			line += "  " + menuDetailStyle.Render(item.detail)
+		}
+		lines = append(lines, ansi.Truncate(line, width, "…"))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const CONFIG_FILE_NAME = "config.json"
+
+type Config struct {
+	Storage StorageConfig `json:"storage"`
+	Sync    SyncConfig    `json:"sync"`
+	Session SessionConfig `json:"session"`
+	Chat    ChatConfig    `json:"chat"`
+	Keys    KeysConfig    `json:"keys"`
+	UI      UIConfig      `json:"ui"`
+	Notify  NotifyConfig  `json:"notify"`
+
+	Templates []Template `json:"templates"`
+	Snippets  []Snippet  `json:"snippets"`
+	Themes    []Theme    `json:"themes"` // picked by name with ui.theme
+}
+
+type StorageConfig struct {
+	Backend        string `json:"backend"` // "file" (default), "bolt" or "s3"
+	BoltPath       string `json:"bolt_path"`
+	S3Bucket       string `json:"s3_bucket"`
+	S3Prefix       string `json:"s3_prefix"`
+	TrashGraceDays int    `json:"trash_grace_days"` // trashed chats are purged after this
+}
+
+type SyncConfig struct {
+	Remote    string `json:"remote"`
+	OnStartup bool   `json:"on_startup"`
+}
+
+type SessionConfig struct {
+	ResumeLast bool `json:"resume_last"` // reopen the most recently updated session
+	AutoTitle  bool `json:"auto_title"`  // name new chats from their first exchange
+	Autosave   bool `json:"autosave"`    // store the chat after every response
+
+	Open uint32 `json:"-"` // session to open at startup (--session)
+}
+
+// ChatConfig picks the backend for new sessions; existing sessions keep the
+// one they were started with.
+type ChatConfig struct {
+	Provider string `json:"provider"` // "echo" (default), "claude", "claude-code", "gemini", "openai", "anthropic" or "ollama"
+	Model    string `json:"model"`    // empty leaves it to the provider; /model changes it per chat
+
+	// System is the system prompt of chats that set none with /system or a
+	// template; Systems overrides it per provider.
+	System  string            `json:"system"`
+	Systems map[string]string `json:"systems"`
+
+	// Params are the generation parameters of chats that set none with
+	// /params.
+	Params Params `json:"params"`
+
+	// Models are what each provider starts with when a chat is switched to
+	// it with /provider; without one the CLI picks.
+	Models map[string]string `json:"models"`
+
+	// Prices add to or override the built-in prices costs are estimated
+	// with, by model name or prefix; see prices.
+	Prices map[string]Price `json:"prices"`
+
+	// ContextTokens is how much history may go with each message; 0 sends
+	// the message alone. Older messages past it are dropped ("truncate") or
+	// folded into a summary ("summarize").
+	ContextTokens   int    `json:"context_tokens"`
+	ContextStrategy string `json:"context_strategy"`
+
+	// Commands add providers, or replace the built-in ones, by the command
+	// line that answers a prompt, e.g. "claude -p {stdin}" or
+	// "llm -m {model} {prompt}"; see commandProvider.
+	Commands map[string]string `json:"commands"`
+
+	// Filters replace the output filters of a provider, applied in order
+	// to its responses: "line_endings", "ansi", "progress", "banner" and
+	// "gemini". The CLIs have some by default, see filtersFor; [] turns
+	// them off. "progress" is for CLIs that draw progress bars, and is
+	// only used when asked for.
+	Filters map[string][]string `json:"filters"`
+
+	// a command still running after this many seconds is killed; Timeouts
+	// overrides it per provider, 0 waits forever
+	TimeoutSeconds int            `json:"timeout_seconds"`
+	Timeouts       map[string]int `json:"timeouts"`
+
+	// no more than this many requests a minute go to a provider; more
+	// wait for their turn. RateLimits overrides it per provider, 0 does
+	// not limit
+	RequestsPerMinute int            `json:"requests_per_minute"`
+	RateLimits        map[string]int `json:"rate_limits"`
+
+	// failures that may pass (rate limits, overloaded servers, network
+	// errors) are retried this many times, waiting longer each time
+	Retries int `json:"retries"`
+}
+
+// NotifyConfig says how a response is announced when nobody is watching:
+// the terminal window is not focused or the response took a while.
+type NotifyConfig struct {
+	Bell         bool `json:"bell"`
+	Desktop      bool `json:"desktop"`       // OSC 777, for terminals that turn it into a desktop notification
+	AfterSeconds int  `json:"after_seconds"` // responses slower than this are announced even when focused; 0 never
+}
+
+// KeysConfig rebinds keys, written the way Bubble Tea names them
+// ("ctrl+y", "alt+c", ...).
+type KeysConfig struct {
+	CopyResponse string `json:"copy_response"`
+}
+
+type UIConfig struct {
+	InputHeight int    `json:"input_height"` // lines in the input box; ctrl+up/down changes it
+	Theme       string `json:"theme"`        // a built-in theme or one from themes
+	Background  string `json:"background"`   // "auto" (default), "dark" or "light"
+	Timestamps  string `json:"timestamps"`   // "off" (default), "relative" or "clock"; ctrl+t cycles
+	FoldLines   int    `json:"fold_lines"`   // responses longer than this show their first lines; 0 never folds
+	Plain       bool   `json:"plain"`        // line-by-line output for screen readers; also -plain
+
+	Roles      RolesConfig `json:"roles"`       // labels, icons and colors of user, bot and system
+	HideLabels bool        `json:"hide_labels"` // a colored bar instead of the labels
+	Compact    bool        `json:"compact"`     // no borders or margins, for small windows; alt+m toggles
+
+	// the input box
+	LineNumbers bool   `json:"line_numbers"` // alt+L toggles
+	Prompt      string `json:"prompt"`       // drawn in front of every line
+	Placeholder string `json:"placeholder"`
+}
+
+func (c StorageConfig) TrashGrace() time.Duration {
+	return time.Duration(c.TrashGraceDays) * 24 * time.Hour
+}
+
+// SystemPrompt is the system prompt of a chat that sets none itself, and
+// where it comes from.
+func (c ChatConfig) SystemPrompt(provider string) (string, string) {
+	if system, ok := c.Systems[provider]; ok {
+		return system, "for " + provider
+	}
+	return c.System, "from the config"
+}
+
+// DefaultModel is the model of a chat switched to provider.
+func (c ChatConfig) DefaultModel(provider string) string {
+	if model, ok := c.Models[provider]; ok {
+		return model
+	}
+	if provider == c.Provider {
+		return c.Model
+	}
+	return ""
+}
+
+func defaultConfig() Config {
+	return Config{
+		Storage: StorageConfig{
+			Backend:        "file",
+			BoltPath:       filepath.Join(FOLDER_NAME, BOLT_DB_NAME),
+			TrashGraceDays: 30,
+		},
+		Chat: ChatConfig{
+			Provider:        DEFAULT_PROVIDER,
+			ContextTokens:   DEFAULT_CONTEXT_TOKENS,
+			ContextStrategy: "truncate",
+			TimeoutSeconds:  300,
+			Retries:         2,
+		},
+		Keys: KeysConfig{
+			CopyResponse: "ctrl+y",
+		},
+		UI: UIConfig{
+			InputHeight: 3,
+			Theme:       DEFAULT_THEME,
+			Background:  "auto",
+			Timestamps:  "off",
+			FoldLines:   30,
+			LineNumbers: true,
+			Prompt:      "| ",
+			Placeholder: "Enter your message here",
+		},
+		Session: SessionConfig{
+			ResumeLast: true,
+			AutoTitle:  true,
+			Autosave:   true,
+		},
+		Notify: NotifyConfig{
+			Bell:         true,
+			AfterSeconds: 30,
+		},
+	}
+}
+
+// configPath returns $RELAY_CONFIG, or relay/config.json under the user
+// config directory (~/.config on Linux).
+func configPath() string {
+	if path := os.Getenv("RELAY_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return CONFIG_FILE_NAME
+	}
+	return filepath.Join(dir, "relay", CONFIG_FILE_NAME)
+}
+
+// loadConfig reads the config file if there is one and then applies the
+// RELAY_* environment overrides. A missing file is not an error.
+func loadConfig() (Config, error) {
+	cfg := defaultConfig()
+
+	data, err := os.ReadFile(configPath())
+	if err != nil && !os.IsNotExist(err) {
+		return cfg, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return defaultConfig(), err
+		}
+	}
+
+	cfg.applyEnv()
+	return cfg, nil
+}
+
+func (c *Config) applyEnv() {
+	if v := os.Getenv("RELAY_STORAGE"); v != "" {
+		c.Storage.Backend = v
+	}
+	if v := os.Getenv("RELAY_S3_BUCKET"); v != "" {
+		c.Storage.S3Bucket = v
+	}
+	if v := os.Getenv("RELAY_S3_PREFIX"); v != "" {
+		c.Storage.S3Prefix = v
+	}
+	if v := os.Getenv("RELAY_SYNC_REMOTE"); v != "" {
+		c.Sync.Remote = v
+	}
+	if os.Getenv("RELAY_SYNC_ON_STARTUP") != "" {
+		c.Sync.OnStartup = true
+	}
+	if v := os.Getenv("RELAY_PROVIDER"); v != "" {
+		c.Chat.Provider = v
+	}
+	if v := os.Getenv("RELAY_MODEL"); v != "" {
+		c.Chat.Model = v
+	}
+	if v := os.Getenv("RELAY_THEME"); v != "" {
+		c.UI.Theme = v
+	}
+	if v := os.Getenv("RELAY_AUTOSAVE"); v != "" {
+		c.Session.Autosave = v != "0" && v != "false"
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// chatRequest is everything needed to ask the backend for the next reply.
+// The context manager decides how much of the history goes with it.
+type chatRequest struct {
+	provider string
+	model    string
+	system   string
+	history  []Message // earlier user and bot messages and summaries, oldest first
+	input    string
+
+	budget     int    // tokens; 0 sends the input alone
+	strategy   string // "truncate" or "summarize"
+	summary    string // stands in for history[:summarized]
+	summarized int
+
+	retries   int       // how often a transient failure is retried
+	attempt   int       // retries made so far
+	notBefore time.Time // when the rate limit lets the request go out, see reserveSlot
+
+	session string // see sessionMeta.Resume
+	params  Params
+}
+
+// DEFAULT_CONTEXT_TOKENS is the history budget unless chat.context_tokens
+// says otherwise; small enough for any CLI's argument limit.
+const DEFAULT_CONTEXT_TOKENS = 8000
+
+// estimateTokens is a rough count: about four characters per token.
+func estimateTokens(text string) int {
+	return (len([]rune(text)) + 3) / 4
+}
+
+// systemPrompt is what goes with each message of the chat: its own system
+// prompt, or else the configured one.
+func (m model) systemPrompt() string {
+	if m.meta.System != "" {
+		return m.meta.System
+	}
+	system, _ := m.chat.SystemPrompt(m.meta.Provider)
+	return system
+}
+
+func (m model) chatRequest(input string) chatRequest {
+	history := []Message{}
+	for _, message := range m.messages[:len(m.messages)-1] {
+		if (message.Role != RoleSystem || message.Pinned) && !message.Collapsed {
+			history = append(history, message)
+		}
+	}
+
+	req := chatRequest{
+		provider:   m.meta.Provider,
+		model:      m.meta.Model,
+		system:     m.systemPrompt(),
+		history:    history,
+		input:      input,
+		budget:     m.chat.ContextTokens,
+		strategy:   m.chat.ContextStrategy,
+		summary:    m.meta.Summary,
+		summarized: m.meta.Summarized,
+		retries:    m.chat.Retries,
+		params:     m.meta.Params.over(m.chat.Params),
+	}
+	if m.meta.Resume != "" && m.meta.ResumeAt == exchanged(m.messages[:len(m.messages)-1]) && req.provider == CLAUDE_CODE {
+		// the backend has the history already
+		req.session, req.history = m.meta.Resume, nil
+	}
+	if req.summarized > len(history) {
+		// messages were undone or deleted since the summary was written
+		req.summary, req.summarized = "", 0
+	}
+	return req
+}
+
+// fit splits the history into the newest messages that fit the budget and
+// the older ones that do not.
+func (r chatRequest) fit() (dropped, kept []Message) {
+	if r.budget <= 0 {
+		return nil, nil
+	}
+
+	room := r.budget - estimateTokens(r.system) - estimateTokens(r.input)
+	if r.strategy == "summarize" {
+		room -= estimateTokens(r.summary)
+	}
+	start := len(r.history)
+	for start > 0 {
+		tokens := estimateTokens(r.history[start-1].Text)
+		if tokens > room {
+			break
+		}
+		room -= tokens
+		start--
+	}
+	return r.history[:start], r.history[start:]
+}
+
+// summarize brings the summary up to date with the messages that no longer
+// fit, asking the backend to fold them into the previous summary.
+func (r *chatRequest) summarize(ctx context.Context, dropped []Message) error {
+	if r.strategy != "summarize" || len(dropped) <= r.summarized {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString("Summarize the conversation below in a short paragraph, keeping facts, decisions and open questions.\n\n")
+	if r.summary != "" {
+		b.WriteString("Summary so far: " + r.summary + "\n\n")
+	}
+	writeMessages(&b, dropped[r.summarized:])
+
+	out, err := askBackend(ctx, r.provider, r.model, b.String())
+	if err != nil {
+		return err
+	}
+	r.summary = strings.TrimSpace(out)
+	r.summarized = len(dropped)
+	return nil
+}
+
+// prompt is what the backend receives: the system prompt, a summary of
+// what was cut, the history that fits, and the new input.
+func (r chatRequest) prompt(dropped, kept []Message) string {
+	var b strings.Builder
+	if r.summary != "" && len(dropped) > 0 && r.strategy == "summarize" {
+		b.WriteString("Summary of the earlier conversation: " + r.summary + "\n\n")
+	}
+	if len(kept) > 0 {
+		writeMessages(&b, kept)
+		b.WriteString("User: ")
+	}
+	if b.Len() == 0 {
+		return withSystem(r.system, r.input)
+	}
+	b.WriteString(r.input)
+	return withSystem(r.system, b.String())
+}
+
+func writeMessages(b *strings.Builder, messages []Message) {
+	for _, message := range messages {
+		b.WriteString(message.Role.Label() + ": " + message.Text + "\n")
+	}
+	b.WriteString("\n")
+}
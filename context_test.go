@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// words is a message of n tokens by estimateTokens.
+func words(role Role, n int) Message {
+	return Message{Role: role, Text: strings.Repeat("abcd", n)}
+}
+
+func TestFit(t *testing.T) {
+	history := []Message{words(RoleUser, 10), words(RoleBot, 20), words(RoleUser, 5), words(RoleBot, 5)}
+	tests := []struct {
+		name    string
+		request chatRequest
+		kept    int
+	}{
+		{"no budget", chatRequest{}, 0},
+		{"everything fits", chatRequest{budget: 100}, 4},
+		{"exactly fits", chatRequest{budget: 40}, 4},
+		{"the oldest goes", chatRequest{budget: 39}, 3},
+		{"the input counts", chatRequest{budget: 40, input: "abcd"}, 3},
+		{"the system prompt counts", chatRequest{budget: 30, system: strings.Repeat("abcd", 10)}, 2},
+		{"the summary counts when summarizing", chatRequest{budget: 30, strategy: "summarize", summary: strings.Repeat("abcd", 21)}, 1},
+		{"the summary is not sent when truncating", chatRequest{budget: 30, strategy: "truncate", summary: strings.Repeat("abcd", 11)}, 3},
+		{"a large message stops the rest", chatRequest{budget: 29}, 2},
+		{"nothing fits", chatRequest{budget: 4}, 0},
+	}
+	for _, tt := range tests {
+		tt.request.history = history
+		dropped, kept := tt.request.fit()
+		if tt.request.budget <= 0 {
+			if dropped != nil || kept != nil {
+				t.Errorf("%s: dropped %d and kept %d without a budget", tt.name, len(dropped), len(kept))
+			}
+			continue
+		}
+		if len(kept) != tt.kept || len(dropped)+len(kept) != len(history) {
+			t.Errorf("%s: dropped %d, kept %d; want %d kept", tt.name, len(dropped), len(kept), tt.kept)
+		}
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	hi, hello := Message{Role: RoleUser, Text: "hi"}, Message{Role: RoleBot, Text: "hello"}
+	tests := []struct {
+		name    string
+		request chatRequest
+		dropped []Message
+		kept    []Message
+		prompt  string
+	}{
+		{"the input alone", chatRequest{input: "next"}, nil, nil, "next"},
+		{"with a system prompt", chatRequest{input: "next", system: "be brief"}, nil, nil, "be brief\n\nnext"},
+		{"with history", chatRequest{input: "next"}, nil, []Message{hi, hello}, "User: hi\nBot: hello\n\nUser: next"},
+		{
+			"with a summary of what was dropped",
+			chatRequest{input: "next", strategy: "summarize", summary: "greetings"}, []Message{hi}, []Message{hello},
+			"Summary of the earlier conversation: greetings\n\nBot: hello\n\nUser: next",
+		},
+		{
+			"the summary only stands in for dropped messages",
+			chatRequest{input: "next", strategy: "summarize", summary: "greetings"}, nil, []Message{hi},
+			"User: hi\n\nUser: next",
+		},
+		{
+			"no summary when truncating",
+			chatRequest{input: "next", strategy: "truncate", summary: "greetings"}, []Message{hi}, []Message{hello},
+			"Bot: hello\n\nUser: next",
+		},
+	}
+	for _, tt := range tests {
+		if prompt := tt.request.prompt(tt.dropped, tt.kept); prompt != tt.prompt {
+			t.Errorf("%s: prompt is %q, want %q", tt.name, prompt, tt.prompt)
+		}
+	}
+}
+
+func TestSummarizeFoldsOnlyNewlyDropped(t *testing.T) {
+	dropped := []Message{{Role: RoleUser, Text: "covered"}, {Role: RoleUser, Text: "new one"}, {Role: RoleBot, Text: "new two"}}
+	r := chatRequest{provider: "echo", strategy: "summarize", summary: "so far", summarized: 1}
+	if err := r.summarize(context.Background(), dropped); err != nil {
+		t.Fatal(err)
+	}
+	if r.summarized != 3 {
+		t.Errorf("summarized %d messages, want 3", r.summarized)
+	}
+	// echo repeats the request, which shows what was asked
+	if !strings.Contains(r.summary, "Summary so far: so far") || !strings.Contains(r.summary, "new two") || strings.Contains(r.summary, "covered") {
+		t.Errorf("asked for a summary with %q", r.summary)
+	}
+
+	before := r
+	if err := r.summarize(context.Background(), dropped[:2]); err != nil || r.summary != before.summary || r.summarized != 3 {
+		t.Errorf("summarized again with nothing new: %q, %d, %v", r.summary, r.summarized, err)
+	}
+}
+
+func TestChatRequestHistory(t *testing.T) {
+	m := model{meta: sessionMeta{Provider: "echo", Summary: "earlier", Summarized: 2}}
+	m.messages = []Message{
+		{Role: RoleUser, Text: "folded", Collapsed: true},
+		{Role: RoleUser, Text: "one"},
+		{Role: RoleSystem, Text: "a note"},
+		{Role: RoleBot, Text: "two"},
+		{Role: RoleSystem, Text: "Summary: pinned", Pinned: true},
+		{Role: RoleUser, Text: "the input"},
+	}
+
+	req := m.chatRequest("the input")
+	var texts []string
+	for _, message := range req.history {
+		texts = append(texts, message.Text)
+	}
+	if got := strings.Join(texts, ","); got != "one,two,Summary: pinned" {
+		t.Errorf("history is %s", got)
+	}
+	if req.summary != "earlier" || req.summarized != 2 {
+		t.Errorf("summary %q of %d messages, want the stored one", req.summary, req.summarized)
+	}
+
+	m.meta.Summarized = 4
+	if req := m.chatRequest("the input"); req.summary != "" || req.summarized != 0 {
+		t.Errorf("a summary of more messages than there are is kept: %q of %d", req.summary, req.summarized)
+	}
+}
+
+func TestSummaryDroppedWithItsMessages(t *testing.T) {
+	fresh := func() model {
+		m := model{meta: sessionMeta{Summary: "earlier", Summarized: 2}}
+		m.messages = []Message{{Role: RoleUser, Text: "one"}, {Role: RoleBot, Text: "two"}, {Role: RoleUser, Text: "three"}}
+		return m
+	}
+
+	m := fresh()
+	m.deleteMessages(0, 1)
+	if m.meta.Summary != "" || m.meta.Summarized != 0 {
+		t.Errorf("deleting a message keeps the summary: %q of %d", m.meta.Summary, m.meta.Summarized)
+	}
+
+	m = fresh()
+	m.applySummary(summaryMsg{text: "all of it", count: 2, collapse: true})
+	if m.meta.Summary != "" || m.meta.Summarized != 0 {
+		t.Errorf("collapsing keeps the summary: %q of %d", m.meta.Summary, m.meta.Summarized)
+	}
+	if !m.messages[0].Collapsed || !m.messages[1].Collapsed || m.messages[2].Collapsed {
+		t.Errorf("collapsed %+v", m.messages)
+	}
+}
+
+func TestMergeDropsTheSummary(t *testing.T) {
+	s := newTestStorage(t)
+	into, err := s.Store(0, transcriptToContent(transcript{
+		Messages:    []Message{{Role: RoleUser, Text: "one", Time: 1}, {Role: RoleUser, Text: "three", Time: 3}},
+		sessionMeta: sessionMeta{Summary: "one and three", Summarized: 2},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := s.Store(0, transcriptToContent(transcript{Messages: []Message{{Role: RoleUser, Text: "two", Time: 2}}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mergeSessions(s, into, from); err != nil {
+		t.Fatal(err)
+	}
+	content, err := s.Get(into)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := decodeTranscript(content.Content)
+	if len(merged.Messages) != 3 || merged.Messages[1].Text != "two" {
+		t.Errorf("merged %+v", merged.Messages)
+	}
+	if merged.Summary != "" || merged.Summarized != 0 {
+		t.Errorf("the merged chat keeps the summary: %q of %d", merged.Summary, merged.Summarized)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"cmp"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// editorDoneMsg comes back when the external editor exits; path holds what
+// was written.
+type editorDoneMsg struct {
+	path string
+	err  error
+}
+
+// editorCommand is $VISUAL or $EDITOR, which may carry flags ("code -w").
+// One that is only blanks counts as unset.
+func editorCommand(path string) *exec.Cmd {
+	args := strings.Fields(cmp.Or(strings.TrimSpace(os.Getenv("VISUAL")), strings.TrimSpace(os.Getenv("EDITOR")), "vi"))
+	return exec.Command(args[0], append(args[1:], path)...)
+}
+
+// openEditor suspends the TUI and edits the input in an external editor,
+// starting from what is already typed.
+func (m model) openEditor() (tea.Model, tea.Cmd) {
+	f, err := os.CreateTemp("", "relay-*.md")
+	if err != nil {
+		return m, m.showToast("Could not open the editor: " + err.Error())
+	}
+	_, err = f.WriteString(m.textarea.Value())
+	f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return m, m.showToast("Could not open the editor: " + err.Error())
+	}
+
+	path := f.Name()
+	return m, tea.ExecProcess(editorCommand(path), func(err error) tea.Msg {
+		return editorDoneMsg{path: path, err: err}
+	})
+}
+
+func (m *model) applyEditor(msg editorDoneMsg) tea.Cmd {
+	defer os.Remove(msg.path)
+	if msg.err != nil {
+		return m.showToast("Editor failed: " + msg.err.Error())
+	}
+	data, err := os.ReadFile(msg.path)
+	if err != nil {
+		return m.showToast("Could not read the edited text: " + err.Error())
+	}
+	m.textarea.SetValue(strings.TrimRight(string(data), "\n"))
+	return nil
+}
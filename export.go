@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const EXPORT_FOLDER_NAME = "exports"
+
+// renderMarkdown writes a transcript as Markdown: a heading per message
+// with its role and time. Message text goes in as-is so fenced code keeps
+// its language tag; an unclosed fence is closed so it cannot swallow the
+// messages after it.
+func renderMarkdown(title string, messages []Message) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "_Exported %s_\n", time.Now().Format("2006-01-02 15:04"))
+
+	for _, message := range messages {
+		b.WriteString("\n## " + message.Role.Label())
+		if message.Time != 0 {
+			b.WriteString(" · " + time.Unix(message.Time, 0).Format("2006-01-02 15:04"))
+		}
+		b.WriteString("\n\n" + strings.TrimRight(message.Text, "\n") + "\n")
+		if strings.Count(message.Text, "```")%2 == 1 {
+			b.WriteString("```\n")
+		}
+	}
+	return b.String()
+}
+
+// exportMarkdown writes the chat under chat/exports and returns the path.
+func exportMarkdown(id uint32, title string, messages []Message) (string, error) {
+	dir := filepath.Join(FOLDER_NAME, EXPORT_FOLDER_NAME)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	name := "chat"
+	if id != 0 {
+		name = fmt.Sprintf("session-%d", id)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.md", name, time.Now().Format("20060102-150405")))
+	if err := os.WriteFile(path, []byte(renderMarkdown(title, messages)), 0644); err != nil {
+		return "", err
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path, nil
+}
+
+// exportSession writes the file in the background and reports the path
+// through the pipe.
+func (m *model) exportSession() {
+	if len(m.messages) == 0 {
+		m.addMessage(RoleSystem, "Nothing to export yet")
+		return
+	}
+
+	title := m.meta.Title
+	if title == "" {
+		title = m.sessionTitle()
+	}
+	id, messages := m.currentId, append([]Message(nil), m.messages...)
+
+	go func() {
+		path, err := exportMarkdown(id, title, messages)
+		if err != nil {
+			m.pipe <- "Error exporting chat: " + err.Error()
+			return
+		}
+		m.pipe <- "Exported chat to " + path
+	}()
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Responses go through a chain of filters before they are shown or
+// stored, to take out what a CLI writes for a terminal rather than for
+// the answer. Filters see whole lines, so output that is filtered streams
+// in a line at a time. chat.filters sets the chain per provider.
+
+// outputFilter cleans up lines of a response; start says nothing of the
+// answer has come through yet.
+type outputFilter func(lines string, start bool) string
+
+var outputFilters = map[string]outputFilter{
+	"line_endings": func(lines string, start bool) string { return strings.ReplaceAll(lines, "\r\n", "\n") },
+	"ansi":         func(lines string, start bool) string { return ansi.Strip(lines) },
+	"progress":     dropProgress,
+	"banner":       trimBanner,
+	"gemini":       func(lines string, start bool) string { return dropLines(lines, geminiNoise) },
+}
+
+// cliFilters are what the output of a CLI goes through unless
+// defaultFilters or chat.filters say otherwise; the APIs answer in plain
+// text and go through none. "progress" can take lines of an answer for a
+// progress bar, so only providers that draw them should ask for it.
+var cliFilters = []string{"line_endings", "ansi"}
+
+var defaultFilters = map[string][]string{
+	"gemini": {"line_endings", "ansi", "banner", "gemini"},
+}
+
+// configuredFilters are chat.filters, see setFilters.
+var configuredFilters = map[string][]string{}
+
+func setFilters(chat ChatConfig) error {
+	for provider, names := range chat.Filters {
+		for _, name := range names {
+			if _, ok := outputFilters[name]; !ok {
+				return fmt.Errorf("unknown filter %q for %s; known: %s", name, provider, strings.Join(filterNames(), ", "))
+			}
+		}
+		configuredFilters[provider] = names
+	}
+	return nil
+}
+
+func filterNames() []string {
+	names := make([]string, 0, len(outputFilters))
+	for name := range outputFilters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func filtersFor(provider string) []string {
+	if names, ok := configuredFilters[provider]; ok {
+		return names
+	}
+	if names, ok := defaultFilters[provider]; ok {
+		return names
+	}
+	if isAPI(provider) {
+		return nil
+	}
+	return cliFilters
+}
+
+// pipeline runs a response through filters as it streams in.
+type pipeline struct {
+	filters []outputFilter
+	pending string // the start of a line yet to end
+	out     strings.Builder
+}
+
+func newPipeline(names []string) *pipeline {
+	p := &pipeline{}
+	for _, name := range names {
+		if filter, ok := outputFilters[name]; ok {
+			p.filters = append(p.filters, filter)
+		}
+	}
+	return p
+}
+
+// write takes the next of the output and returns what of it made it
+// through; the end of an unfinished line waits for the rest.
+func (p *pipeline) write(text string) string {
+	if len(p.filters) == 0 {
+		p.out.WriteString(text)
+		return text
+	}
+	p.pending += text
+	i := strings.LastIndex(p.pending, "\n")
+	if i < 0 {
+		return ""
+	}
+	lines := p.pending[:i+1]
+	p.pending = p.pending[i+1:]
+	return p.pass(lines)
+}
+
+// flush returns what is left once the output has ended.
+func (p *pipeline) flush() string {
+	lines := p.pending
+	p.pending = ""
+	return p.pass(lines)
+}
+
+func (p *pipeline) pass(lines string) string {
+	for _, filter := range p.filters {
+		lines = filter(lines, p.out.Len() == 0)
+	}
+	p.out.WriteString(lines)
+	return lines
+}
+
+// String is all that made it through.
+func (p *pipeline) String() string {
+	return p.out.String()
+}
+
+// filterText runs all of text through the filters at once.
+func filterText(names []string, text string) string {
+	p := newPipeline(names)
+	p.write(text)
+	p.flush()
+	return p.String()
+}
+
+// dropLines removes the lines of text that match pattern.
+func dropLines(text string, pattern *regexp.Regexp) string {
+	if pattern == nil {
+		return text
+	}
+	lines := strings.SplitAfter(text, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !pattern.MatchString(strings.TrimSpace(line)) {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "")
+}
+
+// progressBar matches a drawn progress bar with its percentage, like
+// "[#####.....] 50%" or "█████░░░░░ 50%"; spinnerGlyph matches a spinner
+// glyph at the start of a line.
+var (
+	progressBar  = regexp.MustCompile(`(\[[#=>.\- ]{5,}\]|[█▓▒░]{5,})\s*\d{1,3}(\.\d+)?%`)
+	spinnerGlyph = regexp.MustCompile(`^[⠀-⣿◐◓◑◒]\s`)
+)
+
+// dropProgress keeps of each line what a terminal would show, the part
+// after the last carriage return, and drops progress bars. A spinner only
+// counts as progress on a line drawn over with carriage returns.
+func dropProgress(lines string, start bool) string {
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(lines, "\n") {
+		text, newline := strings.CutSuffix(line, "\n")
+		text = strings.TrimSuffix(text, "\r")
+		i := strings.LastIndex(text, "\r")
+		if i >= 0 {
+			text = text[i+1:]
+		}
+		shown := strings.TrimSpace(text)
+		if i >= 0 && (shown == "" || spinnerGlyph.MatchString(shown)) || progressBar.MatchString(shown) {
+			continue
+		}
+		b.WriteString(text)
+		if newline {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// bannerLine matches lines that only decorate: rules, box edges and boxed
+// text.
+var bannerLine = regexp.MustCompile(`^([\s─━│┃┌┐└┘├┤┬┴┼╭╮╯╰═║╔╗╚╝╠╣╦╩╬=*#~_-]*|[│┃║].*[│┃║])$`)
+
+// trimBanner drops the blank and decorative lines a CLI starts with.
+func trimBanner(lines string, start bool) string {
+	if !start {
+		return lines
+	}
+	rest := lines
+	for rest != "" {
+		line, after, _ := strings.Cut(rest, "\n")
+		if !bannerLine.MatchString(strings.TrimSpace(line)) {
+			break
+		}
+		rest = after
+	}
+	return rest
+}
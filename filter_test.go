@@ -0,0 +1,81 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		filter string
+		start  bool
+		in     string
+		out    string
+	}{
+		{"line_endings", true, "a\r\nb\r\n", "a\nb\n"},
+		{"ansi", true, "\x1b[31mred\x1b[0m\n", "red\n"},
+		{"progress", true, "loading 10%\rloading 100%\rdone\n", "done\n"},
+		{"progress", true, "[#####.....] 50%\nanswer\n", "answer\n"},
+		{"progress", true, "█████░░░░░ 50%\n", ""},
+		{"progress", true, "⠋ thinking\r⠙ thinking\r\nanswer\n", "answer\n"},
+		{"progress", true, "⠋ a spinner glyph in the answer\n", "⠋ a spinner glyph in the answer\n"},
+		{"progress", true, "it is 50% done\n", "it is 50% done\n"},
+		{"banner", true, "\n╭────╮\n│ Tool │\n╰────╯\n\nanswer\n---\n", "answer\n---\n"},
+		{"banner", false, "---\nmore\n", "---\nmore\n"},
+		{"gemini", true, "Loaded cached credentials.\nanswer\n[DEBUG] x\n", "answer\n"},
+	}
+	for _, tt := range tests {
+		if out := outputFilters[tt.filter](tt.in, tt.start); out != tt.out {
+			t.Errorf("%s(%q, %v) = %q, want %q", tt.filter, tt.in, tt.start, out, tt.out)
+		}
+	}
+}
+
+func TestPipelineStreams(t *testing.T) {
+	names := []string{"line_endings", "ansi", "progress", "banner"}
+	output := "\n═══\r\n\x1b[1mthe\x1b[0m answer\r\n 10%\r100%\rstill it\nlast line"
+	want := filterText(names, output)
+	if want != "the answer\nstill it\nlast line" {
+		t.Fatalf("filterText = %q", want)
+	}
+
+	for _, size := range []int{1, 2, 3, 7, len(output)} {
+		p := newPipeline(names)
+		streamed := ""
+		for chunk := range slices.Chunk([]byte(output), size) {
+			streamed += p.write(string(chunk))
+		}
+		streamed += p.flush()
+		if streamed != want || p.String() != want {
+			t.Errorf("in chunks of %d: streamed %q, kept %q; want %q", size, streamed, p.String(), want)
+		}
+	}
+
+	// without filters the text passes as it comes, unfinished lines too
+	p := newPipeline(nil)
+	if out := p.write("partial"); out != "partial" {
+		t.Errorf("an empty pipeline holds back %q", out)
+	}
+}
+
+func TestFiltersFor(t *testing.T) {
+	defer clear(configuredFilters)
+	if err := setFilters(ChatConfig{Filters: map[string][]string{"mine": {"ansi"}, "gemini": {}}}); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string][]string{
+		"mine":      {"ansi"},
+		"gemini":    {},
+		"claude":    cliFilters,
+		"anthropic": nil,
+	}
+	for provider, want := range tests {
+		if names := filtersFor(provider); !slices.Equal(names, want) {
+			t.Errorf("filtersFor(%q) = %q, want %q", provider, names, want)
+		}
+	}
+
+	if err := setFilters(ChatConfig{Filters: map[string][]string{"mine": {"nope"}}}); err == nil {
+		t.Error("an unknown filter is accepted")
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Find (ctrl+f, or / in normal and select mode) searches the open
+// transcript as you type. Enter leaves the query in place so n/N can step
+// through the matches; esc clears it.
+
+var (
+	findMatchStyle   = lipgloss.NewStyle()
+	findCurrentStyle = lipgloss.NewStyle()
+)
+
+// findMatch is a match in the rendered transcript, as byte offsets into the
+// unstyled line.
+type findMatch struct {
+	line       int
+	start, end int
+}
+
+type finder struct {
+	input   textinput.Model
+	typing  bool
+	from    int  // viewport offset when find was opened
+	back    mode // mode to return to on close
+	matches []findMatch
+	current int
+}
+
+// findMatches looks for query, ignoring case, in the text of content.
+func findMatches(content, query string) []findMatch {
+	if query == "" {
+		return nil
+	}
+	re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(query))
+
+	var matches []findMatch
+	for i, line := range strings.Split(ansi.Strip(content), "\n") {
+		for _, at := range re.FindAllStringIndex(line, -1) {
+			matches = append(matches, findMatch{line: i, start: at[0], end: at[1]})
+		}
+	}
+	return matches
+}
+
+// highlightMatches redraws the lines holding a match as plain text with the
+// matches marked; the other lines keep their styling.
+func highlightMatches(content string, matches []findMatch, current int) string {
+	if len(matches) == 0 {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(matches); {
+		n := matches[i].line
+		plain := ansi.Strip(lines[n])
+
+		var b strings.Builder
+		last := 0
+		for ; i < len(matches) && matches[i].line == n; i++ {
+			match := matches[i]
+			style := findMatchStyle
+			if i == current {
+				style = findCurrentStyle
+			}
+			b.WriteString(plain[last:match.start] + style.Render(plain[match.start:match.end]))
+			last = match.end
+		}
+		b.WriteString(plain[last:])
+		lines[n] = b.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m model) openFind() (model, tea.Cmd) {
+	ti := textinput.New()
+	ti.Prompt = "Find: "
+	ti.Focus()
+	m.find = finder{input: ti, typing: true, from: m.viewport.YOffset, back: m.mode}
+	m.mode = modeFind
+	m.textarea.Blur()
+	return m, textinput.Blink
+}
+
+func (m model) closeFind() model {
+	m = m.closeOverlay()
+	if m.find.back == modeNormal {
+		m.mode = modeNormal
+		m.textarea.Blur()
+	}
+	m.find.matches = nil
+	m.refreshTranscript()
+	return m
+}
+
+// showMatch scrolls the current match into the middle of the transcript
+// when it is off screen.
+func (m *model) showMatch() {
+	m.refreshTranscript()
+	if len(m.find.matches) == 0 {
+		return
+	}
+	line := m.find.matches[m.find.current].line
+	if line < m.viewport.YOffset || line >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(line - m.viewport.Height/2)
+	}
+}
+
+func (m *model) stepMatch(step int) {
+	if len(m.find.matches) == 0 {
+		return
+	}
+	m.find.current = (m.find.current + step + len(m.find.matches)) % len(m.find.matches)
+	m.showMatch()
+}
+
+func (m model) updateFind(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	keys := m.keys.Find
+	if !m.find.typing {
+		switch {
+		case key.Matches(msg, keys.Close):
+			return m.closeFind(), nil
+		case key.Matches(msg, keys.Next, keys.Done):
+			m.stepMatch(1)
+		case key.Matches(msg, keys.Prev):
+			m.stepMatch(-1)
+		case key.Matches(msg, keys.Edit):
+			m.find.typing = true
+			m.find.input.Focus()
+			return m, textinput.Blink
+		}
+		return m, nil
+	}
+
+	// while typing, letters go to the query
+	typed := msg.Type == tea.KeyRunes
+	switch {
+	case key.Matches(msg, keys.Close) && !typed:
+		return m.closeFind(), nil
+	case key.Matches(msg, keys.Done):
+		if len(m.find.matches) == 0 {
+			return m.closeFind(), nil
+		}
+		m.find.typing = false
+		m.find.input.Blur()
+		return m, nil
+	case key.Matches(msg, keys.Next) && !typed:
+		m.stepMatch(1)
+		return m, nil
+	case key.Matches(msg, keys.Prev) && !typed:
+		m.stepMatch(-1)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.find.input, cmd = m.find.input.Update(msg)
+
+	// jump to the first match below where find was opened
+	m.refreshTranscript()
+	m.find.current = 0
+	for i, match := range m.find.matches {
+		if match.line >= m.find.from {
+			m.find.current = i
+			break
+		}
+	}
+	m.showMatch()
+	return m, cmd
+}
+
+// findBar replaces the input box while find is open.
+func (m model) findBar() string {
+	count := menuDetailStyle.Render("no matches")
+	if len(m.find.matches) > 0 {
+		count = menuDetailStyle.Render(fmt.Sprintf("%d/%d", m.find.current+1, len(m.find.matches)))
+	}
+	if m.find.typing {
+		return m.find.input.View() + "  " + count
+	}
+	keys := m.keys.Find
+	return fmt.Sprintf("Find: %s  %s  %s", m.find.input.Value(), count, hint(keys.Next, keys.Prev, keys.Edit, keys.Close))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"regexp"
+)
+
+// The gemini CLI wraps its answer in notices about credentials and
+// telemetry, and its errors in stack traces; the adapter keeps the answer
+// and turns the errors into something to act on.
+
+// geminiNoise matches the lines gemini writes around the answer.
+var geminiNoise = regexp.MustCompile(`^(Loaded cached credentials\.|Data collection is disabled\.|Flushing log events.*|\[(DEBUG|INFO|WARN|dotenv)[^\]]*\].*|` +
+	`YOLO mode is enabled.*|Using .*(auth|credentials).*)$`)
+
+// geminiErrors map what gemini writes to stderr to what went wrong, and
+// to the status of the API error behind it, which decides on a retry.
+var geminiErrors = []struct {
+	pattern *regexp.Regexp
+	code    int
+	message string
+}{
+	{regexp.MustCompile(`(?i)quota|RESOURCE_EXHAUSTED|\b429\b`), 429, "gemini hit its quota or rate limit"},
+	{regexp.MustCompile(`(?i)API key not valid|API_KEY_INVALID`), 400, "gemini rejected the API key; check GEMINI_API_KEY"},
+	{regexp.MustCompile(`(?i)set an Auth method|not authenticated|login required`), 401, "gemini is not signed in; run gemini once to choose how to log in"},
+	{regexp.MustCompile(`(?i)models/\S+ is not found|model.*not found|\bNOT_FOUND\b`), 404, "gemini does not know this model; /model picks another"},
+	{regexp.MustCompile(`(?i)PERMISSION_DENIED|\b403\b`), 403, "gemini was denied access; check the account or project"},
+}
+
+var geminiReport = regexp.MustCompile(`Full report available at: (\S+)`)
+
+// explainGemini returns the reason for a failure, with the path of the
+// report gemini saved, or the stderr as it is when nothing matches.
+func explainGemini(stderr string) error {
+	stderr = dropLines(stderr, geminiNoise)
+	for _, known := range geminiErrors {
+		if known.pattern.MatchString(stderr) {
+			message := known.message
+			if report := geminiReport.FindStringSubmatch(stderr); report != nil {
+				message += " (details in " + report[1] + ")"
+			}
+			return statusError{code: known.code, message: message}
+		}
+	}
+	return plainExplain(stderr)
+}
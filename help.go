@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// help lists the key map and the slash commands. It remembers the mode it
+// was opened from so normal and select mode pick up where they were.
+type help struct {
+	back   mode
+	scroll int
+}
+
+type helpSection struct {
+	title    string
+	bindings []key.Binding
+}
+
+func (k keyMap) sections() []helpSection {
+	c, n, s, f := k.Chat, k.Normal, k.Select, k.Find
+	return []helpSection{
+		{"Chat", []key.Binding{c.Send, c.Newline, c.Editor, c.Detach, c.Cancel, c.HistoryPrev, c.PageUp, c.PageDown, c.Top, c.Bottom, c.Taller, c.Shorter, c.Normal, c.Focus, c.Complete, c.Find, c.Select, c.Jump, c.Palette, c.Bookmarks, c.Undo, c.Times, c.LineNumbers, c.Compact, c.CopyResponse, c.CopyCode, c.OpenLink, c.Save, c.Sync, c.Rename, c.NewSession, c.Templates, c.Snippets, c.Sessions, c.Sidebar, c.Checkpoint, c.Checkpoints, c.Export, c.Stats, c.Notices, c.Help, c.Quit}},
+		{"Normal mode", []key.Binding{n.Down, n.Up, n.HalfDown, n.HalfUp, n.PageDown, n.PageUp, n.Top, n.Bottom, n.Find, n.Select, n.Insert, n.Focus, n.Help}},
+		{"Select mode", []key.Binding{s.Down, s.Up, s.Fork, s.Edit, s.Reuse, s.Expand, s.Compare, s.Bookmark, s.Delete, s.DeletePair, s.Find, s.Back}},
+		{"Find", []key.Binding{f.Next, f.Prev, f.Done, f.Edit, f.Close}},
+	}
+}
+
+func (m model) openHelp() (model, tea.Cmd) {
+	m.help = help{back: m.mode}
+	m.mode = modeHelp
+	m.textarea.Blur()
+	return m, nil
+}
+
+func (m model) closeHelp() model {
+	m.mode = m.help.back
+	if m.mode == modeChat {
+		m.textarea.Focus()
+	}
+	m.refreshTranscript()
+	return m
+}
+
+func (m model) updateHelp(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q", "?", "f1":
+		return m.closeHelp(), nil
+	case "up", "k":
+		m.help.scroll = max(m.help.scroll-1, 0)
+	case "down", "j":
+		lines := len(m.helpLines(m.viewport.Width))
+		m.help.scroll = min(m.help.scroll+1, max(lines-m.viewport.Height+len(helpHeader), 0))
+	}
+	return m, nil
+}
+
+func helpColumn(sections []helpSection) []string {
+	var lines []string
+	for _, section := range sections {
+		width := 0
+		for _, b := range section.bindings {
+			width = max(width, lipgloss.Width(b.Help().Key))
+		}
+
+		lines = append(lines, menuTitleStyle.Render(section.title))
+		for _, b := range section.bindings {
+			if !b.Enabled() {
+				continue
+			}
+			pad := strings.Repeat(" ", width-lipgloss.Width(b.Help().Key))
+			lines = append(lines, "  "+menuSelectedStyle.Render(b.Help().Key)+pad+"  "+b.Help().Desc)
+		}
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+var helpHeader = []string{
+	menuTitleStyle.Render("Keys"),
+	menuDetailStyle.Render("j/k scroll · esc close"),
+	"",
+}
+
+// helpLines puts the chat keys beside everything else when there is room.
+func (m model) helpLines(width int) []string {
+	sections := m.keys.sections()
+	commands := helpSection{title: "Slash commands"}
+	for _, command := range slashCommands {
+		commands.bindings = append(commands.bindings, key.NewBinding(key.WithKeys(command.usage), key.WithHelp(command.usage, command.help)))
+	}
+	sections = append(sections, commands)
+
+	left := helpColumn(sections[:1])
+	right := helpColumn(sections[1:])
+	if column := lipgloss.Width(strings.Join(left, "\n")) + 4; column+lipgloss.Width(strings.Join(right, "\n")) <= width {
+		body := lipgloss.JoinHorizontal(lipgloss.Top, lipgloss.NewStyle().Width(column).Render(strings.Join(left, "\n")), strings.Join(right, "\n"))
+		return strings.Split(body, "\n")
+	}
+	return append(left, right...)
+}
+
+// helpView scrolls with j/k when the keys do not fit.
+func (m model) helpView(width, height int) string {
+	lines := m.helpLines(width)
+	visible := max(height-len(helpHeader), 1)
+	scroll := min(m.help.scroll, max(len(lines)-visible, 0))
+	lines = lines[scroll:min(scroll+visible, len(lines))]
+
+	header := append([]string(nil), helpHeader...)
+	if scroll > 0 {
+		header[1] += menuDetailStyle.Render(fmt.Sprintf(" · line %d", scroll+1))
+	}
+	return strings.Join(append(header, lines...), "\n")
+}
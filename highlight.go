@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+const HIGHLIGHT_STYLE = "monokai"
+
+var highlightStyle = HIGHLIGHT_STYLE // set by the theme
+
+// RENDER_CACHE_SIZE bounds renderedResponses; past it the cache starts
+// over.
+const RENDER_CACHE_SIZE = 2000
+
+type renderKey struct {
+	text  string
+	style string
+	width int
+}
+
+// renderedResponses keeps responses as renderResponse left them. The
+// transcript is drawn again on every streamed chunk and every key in
+// select mode, and highlighting each code block every time made long
+// chats crawl.
+var renderedResponses = map[renderKey]string{}
+
+// renderResponse lays out the tables of a bot message for width and
+// highlights its code.
+func renderResponse(text string, width int) string {
+	key := renderKey{text: text, style: highlightStyle, width: width}
+	if rendered, ok := renderedResponses[key]; ok {
+		return rendered
+	}
+	rendered := highlightCode(renderTables(text, width))
+	if len(renderedResponses) >= RENDER_CACHE_SIZE {
+		clear(renderedResponses)
+	}
+	renderedResponses[key] = rendered
+	return rendered
+}
+
+// highlightCode colours the fenced code blocks in a message, picking the
+// lexer from the fence's info string (or guessing when there is none).
+// Fence lines are kept, so the message has the same number of lines.
+func highlightCode(text string) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+
+	for i := 0; i < len(lines); i++ {
+		info, ok := strings.CutPrefix(strings.TrimSpace(lines[i]), "```")
+		if !ok {
+			out = append(out, lines[i])
+			continue
+		}
+
+		end := i + 1
+		for end < len(lines) && strings.TrimSpace(lines[end]) != "```" {
+			end++
+		}
+		code := strings.Join(lines[i+1:min(end, len(lines))], "\n")
+		out = append(out, timeStyle.Render(lines[i]))
+		lang, _, _ := strings.Cut(strings.TrimSpace(info), " ")
+		out = append(out, strings.Split(highlight(code, lang), "\n")...)
+		if end < len(lines) {
+			out = append(out, timeStyle.Render(lines[end]))
+		}
+		i = end
+	}
+	return strings.Join(out, "\n")
+}
+
+func highlight(code, lang string) string {
+	if code == "" {
+		return code
+	}
+
+	var lexer chroma.Lexer
+	if lang != "" {
+		lexer = lexers.Get(lang)
+	}
+	if lexer == nil {
+		lexer = lexers.Analyse(code)
+	}
+	if lexer == nil {
+		return code
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return code
+	}
+	var b strings.Builder
+	if err := formatters.TTY256.Format(&b, styles.Get(highlightStyle), iterator); err != nil {
+		return code
+	}
+	colored := strings.TrimSuffix(b.String(), "\n")
+	if strings.Count(colored, "\n") != strings.Count(code, "\n") {
+		return code
+	}
+	return colored
+}
@@ -0,0 +1,44 @@
+package main
+
+// Up and down on an empty input step through the prompts sent in this
+// session, newest first, like a shell. The prompts are the session's user
+// messages, so the history is saved and restored with it.
+
+// prompts lists what the user sent, oldest first, without repeats in a row.
+func (m model) prompts() []string {
+	var prompts []string
+	for _, message := range m.messages {
+		if message.Role != RoleUser {
+			continue
+		}
+		if len(prompts) > 0 && prompts[len(prompts)-1] == message.Text {
+			continue
+		}
+		prompts = append(prompts, message.Text)
+	}
+	return prompts
+}
+
+// browsingHistory reports whether up/down should recall prompts: the input
+// is empty or still holds the prompt recalled last.
+func (m model) browsingHistory() bool {
+	value := m.textarea.Value()
+	return value == "" || (m.historyAt > 0 && value == m.recalled)
+}
+
+// recall moves step prompts back (1) or forward (-1). Going forward past the
+// newest prompt empties the input again.
+func (m *model) recall(step int) {
+	prompts := m.prompts()
+	at := min(max(m.historyAt+step, 0), len(prompts))
+	if at == m.historyAt {
+		return
+	}
+
+	m.historyAt = at
+	m.recalled = ""
+	if at > 0 {
+		m.recalled = prompts[len(prompts)-at]
+	}
+	m.textarea.SetValue(m.recalled)
+}
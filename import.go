@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Importers for the conversations.json files in the ChatGPT and Claude.ai
+// data exports. Each conversation becomes one session.
+
+type chatgptConversation struct {
+	Title       string                 `json:"title"`
+	CreateTime  float64                `json:"create_time"`
+	UpdateTime  float64                `json:"update_time"`
+	CurrentNode string                 `json:"current_node"`
+	Mapping     map[string]chatgptNode `json:"mapping"`
+}
+
+type chatgptNode struct {
+	Parent  string `json:"parent"`
+	Message *struct {
+		Author struct {
+			Role string `json:"role"`
+		} `json:"author"`
+		CreateTime float64 `json:"create_time"`
+		Content    struct {
+			Parts []json.RawMessage `json:"parts"`
+		} `json:"content"`
+	} `json:"message"`
+}
+
+type claudeConversation struct {
+	Name         string    `json:"name"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	ChatMessages []struct {
+		Sender    string    `json:"sender"`
+		Text      string    `json:"text"`
+		CreatedAt time.Time `json:"created_at"`
+		Content   []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	} `json:"chat_messages"`
+}
+
+// importedChat is a conversation ready to be stored.
+type importedChat struct {
+	transcript
+	createdAt int64
+	updatedAt int64
+}
+
+// parseExport reads either export format; a file may hold a list of
+// conversations or a single one.
+func parseExport(data []byte) ([]importedChat, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		raw = []json.RawMessage{data}
+	}
+
+	chats := []importedChat{}
+	for _, item := range raw {
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(item, &keys); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case keys["mapping"] != nil:
+			var conversation chatgptConversation
+			if err := json.Unmarshal(item, &conversation); err != nil {
+				return nil, err
+			}
+			chats = append(chats, conversation.chat())
+		case keys["chat_messages"] != nil:
+			var conversation claudeConversation
+			if err := json.Unmarshal(item, &conversation); err != nil {
+				return nil, err
+			}
+			chats = append(chats, conversation.chat())
+		default:
+			return nil, errors.New("not a ChatGPT or Claude.ai conversation export")
+		}
+	}
+	return chats, nil
+}
+
+// chat follows the branch that was last shown (current_node back to the
+// root); edited-away branches are left out.
+func (c chatgptConversation) chat() importedChat {
+	messages := []Message{}
+	for id := c.CurrentNode; id != ""; id = c.Mapping[id].Parent {
+		node := c.Mapping[id]
+		if node.Message == nil {
+			continue
+		}
+
+		var parts []string
+		for _, part := range node.Message.Content.Parts {
+			var text string
+			if json.Unmarshal(part, &text) == nil && text != "" {
+				parts = append(parts, text)
+			}
+		}
+		role := RoleUser
+		switch node.Message.Author.Role {
+		case "user":
+		case "assistant":
+			role = RoleBot
+		default:
+			continue // system and tool messages
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		messages = append(messages, Message{Role: role, Text: strings.Join(parts, "\n"), Time: int64(node.Message.CreateTime)})
+	}
+	slices.Reverse(messages)
+
+	return importedChat{
+		transcript: transcript{Messages: messages, sessionMeta: sessionMeta{Title: c.Title, Imported: "chatgpt"}},
+		createdAt:  int64(c.CreateTime),
+		updatedAt:  int64(c.UpdateTime),
+	}
+}
+
+func (c claudeConversation) chat() importedChat {
+	messages := []Message{}
+	for _, message := range c.ChatMessages {
+		text := message.Text
+		if text == "" {
+			var blocks []string
+			for _, block := range message.Content {
+				if block.Type == "text" {
+					blocks = append(blocks, block.Text)
+				}
+			}
+			text = strings.Join(blocks, "\n")
+		}
+		if text == "" {
+			continue
+		}
+
+		role := RoleUser
+		if message.Sender == "assistant" {
+			role = RoleBot
+		}
+		messages = append(messages, Message{Role: role, Text: text, Time: message.CreatedAt.Unix()})
+	}
+
+	return importedChat{
+		transcript: transcript{Messages: messages, sessionMeta: sessionMeta{Title: c.Name, Imported: "claude.ai"}},
+		createdAt:  c.CreatedAt.Unix(),
+		updatedAt:  c.UpdatedAt.Unix(),
+	}
+}
+
+// importFile stores every conversation in path as a new session and
+// returns how many were imported.
+func importFile(storage Store, path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	chats, err := parseExport(data)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+
+	count := 0
+	for _, chat := range chats {
+		if len(chat.Messages) == 0 {
+			continue
+		}
+		content := transcriptToContent(chat.transcript)
+		if chat.createdAt > 0 {
+			content.CreatedAt = chat.createdAt
+		}
+		if chat.updatedAt > 0 {
+			content.UpdatedAt = chat.updatedAt
+		}
+		if _, err := storage.Store(0, content); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
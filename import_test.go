@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// chatgptExport has an edited first prompt: current_node leads back
+// through the edit, not the original.
+const chatgptExport = `[{
+	"title": "Edited",
+	"create_time": 1700000000.5,
+	"update_time": 1700000100.5,
+	"current_node": "answer2",
+	"mapping": {
+		"root": {"parent": "", "message": null},
+		"system": {"parent": "root", "message": {"author": {"role": "system"}, "content": {"parts": ["be brief"]}}},
+		"ask1": {"parent": "system", "message": {"author": {"role": "user"}, "create_time": 1700000001, "content": {"parts": ["first try"]}}},
+		"answer1": {"parent": "ask1", "message": {"author": {"role": "assistant"}, "content": {"parts": ["dropped"]}}},
+		"ask2": {"parent": "system", "message": {"author": {"role": "user"}, "create_time": 1700000002, "content": {"parts": ["second try", {"image": "x"}]}}},
+		"tool": {"parent": "ask2", "message": {"author": {"role": "tool"}, "content": {"parts": ["searched"]}}},
+		"empty": {"parent": "tool", "message": {"author": {"role": "assistant"}, "content": {"parts": [""]}}},
+		"answer2": {"parent": "empty", "message": {"author": {"role": "assistant"}, "create_time": 1700000003, "content": {"parts": ["kept", "in two parts"]}}}
+	}
+}]`
+
+const claudeExport = `{
+	"name": "Claude chat",
+	"created_at": "2024-05-01T10:00:00Z",
+	"updated_at": "2024-05-01T10:05:00Z",
+	"chat_messages": [
+		{"sender": "human", "text": "hello", "created_at": "2024-05-01T10:00:00Z"},
+		{"sender": "assistant", "text": "", "created_at": "2024-05-01T10:00:05Z", "content": [
+			{"type": "text", "text": "from"}, {"type": "tool_use", "text": "ignored"}, {"type": "text", "text": "blocks"}]},
+		{"sender": "assistant", "text": "", "created_at": "2024-05-01T10:00:06Z"}
+	]
+}`
+
+func TestParseExport(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		title    string
+		imported string
+		created  int64
+		messages []Message
+	}{
+		{
+			name: "chatgpt", data: chatgptExport, title: "Edited", imported: "chatgpt", created: 1700000000,
+			messages: []Message{
+				{Role: RoleUser, Text: "second try", Time: 1700000002},
+				{Role: RoleBot, Text: "kept\nin two parts", Time: 1700000003},
+			},
+		},
+		{
+			name: "claude.ai", data: claudeExport, title: "Claude chat", imported: "claude.ai", created: 1714557600,
+			messages: []Message{
+				{Role: RoleUser, Text: "hello", Time: 1714557600},
+				{Role: RoleBot, Text: "from\nblocks", Time: 1714557605},
+			},
+		},
+	}
+	for _, tt := range tests {
+		chats, err := parseExport([]byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(chats) != 1 {
+			t.Errorf("%s: %d chats, want 1", tt.name, len(chats))
+			continue
+		}
+		chat := chats[0]
+		if chat.Title != tt.title || chat.Imported != tt.imported || chat.createdAt != tt.created {
+			t.Errorf("%s: title %q, imported %q, created %d", tt.name, chat.Title, chat.Imported, chat.createdAt)
+		}
+		if !reflect.DeepEqual(chat.Messages, tt.messages) {
+			t.Errorf("%s: messages %+v, want %+v", tt.name, chat.Messages, tt.messages)
+		}
+	}
+}
+
+func TestParseExportErrors(t *testing.T) {
+	for _, data := range []string{`not json`, `[{"title": "neither"}]`, `[1, 2]`} {
+		if _, err := parseExport([]byte(data)); err == nil {
+			t.Errorf("parseExport(%s) did not fail", data)
+		}
+	}
+	if chats, err := parseExport([]byte(`[]`)); err != nil || len(chats) != 0 {
+		t.Errorf("an empty export gives %v, %v", chats, err)
+	}
+}
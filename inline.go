@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Inline mode (--inline) runs on the normal screen instead of the
+// alternate one. Finished messages are printed into the terminal's
+// scrollback, where they stay after relay exits, and only the response
+// coming in, the status bar and the input are redrawn. Other modes still
+// show the transcript box.
+
+// printMessages prints the messages that are not in the scrollback yet.
+// When the chat changed under what was printed (undo, edit, another
+// session) it picks up from where the two part.
+func (m *model) printMessages() tea.Cmd {
+	if !m.inline || m.width == 0 {
+		return nil
+	}
+	from := 0
+	for from < min(len(m.printed), len(m.messages)) && samePrinted(m.printed[from], m.messages[from]) {
+		from++
+	}
+	m.printed = append(m.printed[:0], m.messages...)
+	if from == len(m.messages) {
+		return nil
+	}
+
+	opts := m.transcriptOptions()
+	// the scrollback cannot be selected or unfolded later
+	opts.selected, opts.fold = -1, 0
+	text := renderTranscript(m.messages[from:], opts)
+	return tea.Println(lipgloss.NewStyle().MarginLeft(appStyle.GetMarginLeft()).Render(text))
+}
+
+func samePrinted(a, b Message) bool {
+	return a.Role == b.Role && a.Text == b.Text && a.Time == b.Time
+}
+
+// inlineView is what inline mode draws above the status bar in chat mode:
+// the tail of the response coming in.
+func (m model) inlineView() string {
+	if m.partial == "" {
+		return ""
+	}
+	opts := m.transcriptOptions()
+	opts.fold = 0
+	lines := strings.Split(strings.TrimRight(renderTranscript([]Message{{Role: RoleBot, Text: m.partial}}, opts), "\n"), "\n")
+	return strings.Join(lines[max(len(lines)-m.viewport.Height, 0):], "\n")
+}
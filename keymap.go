@@ -0,0 +1,207 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// keyMap is every key the app reacts to, grouped by the mode that reads
+// it. Update matches against it and the help overlay and hint lines are
+// built from it, so a binding only has to be changed here.
+type keyMap struct {
+	Chat   chatKeys
+	Normal normalKeys
+	Select selectKeys
+	Find   findKeys
+}
+
+type chatKeys struct {
+	Send         key.Binding
+	Newline      key.Binding
+	Editor       key.Binding
+	Detach       key.Binding
+	Cancel       key.Binding
+	PageUp       key.Binding
+	PageDown     key.Binding
+	Top          key.Binding
+	Bottom       key.Binding
+	HistoryPrev  key.Binding
+	HistoryNext  key.Binding
+	Taller       key.Binding
+	Shorter      key.Binding
+	Normal       key.Binding
+	Focus        key.Binding
+	Complete     key.Binding
+	Find         key.Binding
+	Select       key.Binding
+	Jump         key.Binding
+	Undo         key.Binding
+	Times        key.Binding
+	LineNumbers  key.Binding
+	CopyResponse key.Binding
+	CopyCode     key.Binding
+	OpenLink     key.Binding
+	Compact      key.Binding
+	Palette      key.Binding
+	Bookmarks    key.Binding
+	Save         key.Binding
+	Sync         key.Binding
+	Rename       key.Binding
+	NewSession   key.Binding
+	Templates    key.Binding
+	Snippets     key.Binding
+	Sessions     key.Binding
+	Sidebar      key.Binding
+	Checkpoint   key.Binding
+	Checkpoints  key.Binding
+	Export       key.Binding
+	Stats        key.Binding
+	Notices      key.Binding
+	Help         key.Binding
+	Quit         key.Binding
+}
+
+type normalKeys struct {
+	Down     key.Binding
+	Up       key.Binding
+	HalfDown key.Binding
+	HalfUp   key.Binding
+	PageDown key.Binding
+	PageUp   key.Binding
+	Top      key.Binding // gg; matched on the second g
+	Bottom   key.Binding
+	Find     key.Binding
+	Select   key.Binding
+	Insert   key.Binding
+	Focus    key.Binding
+	Help     key.Binding
+	Quit     key.Binding
+}
+
+type selectKeys struct {
+	Down       key.Binding
+	Up         key.Binding
+	Fork       key.Binding
+	Edit       key.Binding
+	Reuse      key.Binding
+	Expand     key.Binding
+	Compare    key.Binding
+	Bookmark   key.Binding
+	Delete     key.Binding
+	DeletePair key.Binding
+	Find       key.Binding
+	Help       key.Binding
+	Back       key.Binding
+}
+
+type findKeys struct {
+	Next  key.Binding
+	Prev  key.Binding
+	Done  key.Binding
+	Edit  key.Binding
+	Close key.Binding
+}
+
+func bind(help, desc string, keys ...string) key.Binding {
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(help, desc))
+}
+
+func newKeyMap(cfg KeysConfig) keyMap {
+	return keyMap{
+		Chat: chatKeys{
+			Send:         bind("enter", "send the message", "enter"),
+			Newline:      bind("shift+enter", "new line", "ctrl+j", "shift+enter"),
+			Editor:       bind("ctrl+e", "write in $EDITOR", "ctrl+e"),
+			Detach:       bind("alt+x", "drop the last attachment", "alt+x"),
+			Cancel:       bind("esc", "cancel the response", "esc", "ctrl+x"),
+			PageUp:       bind("pgup", "page up", "pgup"),
+			PageDown:     bind("pgdn", "page down", "pgdown"),
+			Top:          bind("ctrl+home", "top", "ctrl+home"),
+			Bottom:       bind("ctrl+end", "bottom", "ctrl+end"),
+			HistoryPrev:  bind("↑/↓", "earlier prompts (on an empty input)", "up"),
+			HistoryNext:  bind("↓", "later prompts", "down"),
+			Taller:       bind("ctrl+↑", "taller input", "ctrl+up"),
+			Shorter:      bind("ctrl+↓", "shorter input", "ctrl+down"),
+			Normal:       bind("esc", "normal mode", "esc"),
+			Focus:        bind("tab", "focus the transcript", "tab"),
+			Complete:     bind("tab", "complete a /command", "tab"),
+			Find:         bind("ctrl+f", "find in the chat", "ctrl+f"),
+			Select:       bind("ctrl+l", "select a message", "ctrl+l"),
+			Jump:         bind("ctrl+g", "jump to a message", "ctrl+g"),
+			Palette:      bind("ctrl+k", "find a session or message", "ctrl+k"),
+			Bookmarks:    bind("alt+b", "bookmarks", "alt+b"),
+			Undo:         bind("ctrl+z", "undo the last exchange", "ctrl+z"),
+			Times:        bind("ctrl+t", "message times", "ctrl+t"),
+			LineNumbers:  bind("alt+L", "input line numbers", "alt+L"),
+			Compact:      bind("alt+m", "compact layout", "alt+m"),
+			CopyResponse: bind(cfg.CopyResponse, "copy the last response", cfg.CopyResponse),
+			CopyCode:     bind("alt+y", "copy a code block", "alt+y"),
+			OpenLink:     bind("alt+1…9", "open a link of the last response", "alt+1", "alt+2", "alt+3", "alt+4", "alt+5", "alt+6", "alt+7", "alt+8", "alt+9"),
+			Save:         bind("ctrl+s", "save", "ctrl+s"),
+			Sync:         bind("ctrl+r", "sync", "ctrl+r"),
+			Rename:       bind("f2", "rename", "f2"),
+			NewSession:   bind("ctrl+n", "new chat", "ctrl+n"),
+			Templates:    bind("alt+n", "new chat from a template", "alt+n"),
+			Snippets:     bind("ctrl+p", "insert a snippet", "ctrl+p"),
+			Sessions:     bind("ctrl+o", "open a session", "ctrl+o"),
+			Sidebar:      bind("ctrl+b", "sidebar", "ctrl+b"),
+			Checkpoint:   bind("alt+c", "save a checkpoint", "alt+c"),
+			Checkpoints:  bind("alt+r", "restore a checkpoint", "alt+r"),
+			Export:       bind("alt+e", "export as Markdown", "alt+e"),
+			Stats:        bind("alt+i", "statistics", "alt+i"),
+			Notices:      bind("alt+l", "notification log", "alt+l"),
+			Help:         bind("f1", "help", "f1"),
+			Quit:         bind("ctrl+c", "quit", "ctrl+c"),
+		},
+		Normal: normalKeys{
+			Down:     bind("j", "scroll down", "j", "down"),
+			Up:       bind("k", "scroll up", "k", "up"),
+			HalfDown: bind("ctrl+d", "half page down", "ctrl+d"),
+			HalfUp:   bind("ctrl+u", "half page up", "ctrl+u"),
+			PageDown: bind("pgdn", "page down", "pgdown", " ", "f"),
+			PageUp:   bind("pgup", "page up", "pgup", "b"),
+			Top:      bind("gg/home", "top", "g", "home", "ctrl+home"),
+			Bottom:   bind("G/end", "bottom", "G", "end", "ctrl+end"),
+			Find:     bind("/", "find", "/"),
+			Select:   bind("v", "select", "v"),
+			Insert:   bind("i", "insert", "i", "a", "enter"),
+			Focus:    bind("tab", "focus the sidebar or input", "tab"),
+			Help:     bind("?", "help", "?", "f1"),
+			Quit:     bind("ctrl+c", "quit", "ctrl+c"),
+		},
+		Select: selectKeys{
+			Down:       bind("j", "next message", "j", "down"),
+			Up:         bind("k", "previous message", "k", "up"),
+			Fork:       bind("f", "fork from here", "f"),
+			Edit:       bind("e", "edit and resend", "e"),
+			Reuse:      bind("r", "copy to the input", "r"),
+			Expand:     bind("o", "expand or fold a long response", "o"),
+			Compare:    bind("c", "compare two responses", "c"),
+			Bookmark:   bind("b", "bookmark", "b"),
+			Delete:     bind("d", "delete", "d"),
+			DeletePair: bind("D", "delete with its reply", "D"),
+			Find:       bind("/", "find", "/"),
+			Help:       bind("?", "help", "?", "f1"),
+			Back:       bind("esc", "back", "esc", "ctrl+l"),
+		},
+		Find: findKeys{
+			Next:  bind("n", "next match", "n", "down", "ctrl+n"),
+			Prev:  bind("N", "previous match", "N", "up", "ctrl+p"),
+			Done:  bind("enter", "keep the matches", "enter"),
+			Edit:  bind("/", "edit the query", "/"),
+			Close: bind("esc", "close", "esc", "ctrl+f", "q"),
+		},
+	}
+}
+
+// hint is the one-line key summary shown in place of the input box.
+func hint(bindings ...key.Binding) string {
+	parts := make([]string, 0, len(bindings))
+	for _, b := range bindings {
+		if b.Enabled() {
+			parts = append(parts, b.Help().Key+" "+b.Help().Desc)
+		}
+	}
+	return menuDetailStyle.Render(strings.Join(parts, " · "))
+}
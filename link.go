@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// linkPattern finds URLs in a response. Escapes end a match, so links in
+// highlighted code are cut where the colours change.
+var linkPattern = regexp.MustCompile(`https?://[^\s<>()\[\]"'` + "`" + `\x1b]+`)
+
+// linkMark matches the OSC 8 sequences opening and closing a hyperlink;
+// the URI is empty when it closes one.
+var linkMark = regexp.MustCompile(`\x1b]8;[^;\x07]*;([^\x07]*)\x07`)
+
+// supportsHyperlinks guesses from the environment whether the terminal
+// understands OSC 8. Terminals that do not may print the sequences.
+func supportsHyperlinks() bool {
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "ghostty", "Hyper":
+		return true
+	}
+	if os.Getenv("WT_SESSION") != "" || os.Getenv("KITTY_WINDOW_ID") != "" {
+		return true
+	}
+	if v, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && v >= 5000 {
+		return true
+	}
+	term := os.Getenv("TERM")
+	return strings.Contains(term, "kitty") || strings.Contains(term, "alacritty") || strings.HasPrefix(term, "foot")
+}
+
+// links returns the URLs in text in the order they appear, each once.
+func links(text string) []string {
+	var found []string
+	for _, url := range linkPattern.FindAllString(text, -1) {
+		url = strings.TrimRight(url, ".,;:!?")
+		if !slices.Contains(found, url) {
+			found = append(found, url)
+		}
+	}
+	return found
+}
+
+// linkify makes the URLs in text clickable.
+func linkify(text string) string {
+	return linkPattern.ReplaceAllStringFunc(text, func(match string) string {
+		url := strings.TrimRight(match, ".,;:!?")
+		return ansi.SetHyperlink(url) + url + ansi.ResetHyperlink() + match[len(url):]
+	})
+}
+
+// closeLinks ends every line outside a hyperlink, reopening it on the next
+// line, so a wrapped link does not run into the borders.
+func closeLinks(text string) string {
+	lines := strings.Split(text, "\n")
+	open := ""
+	for i, line := range lines {
+		if open != "" {
+			line = ansi.SetHyperlink(open) + line
+		}
+		for _, mark := range linkMark.FindAllStringSubmatch(line, -1) {
+			open = mark[1]
+		}
+		if open != "" {
+			line += ansi.ResetHyperlink()
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// openLink opens the nth link of the last response in the browser.
+func (m *model) openLink(n int) tea.Cmd {
+	i, ok := m.lastResponse()
+	if !ok {
+		return m.showToast("No response yet")
+	}
+	found := links(m.messages[i].Text)
+	switch {
+	case len(found) == 0:
+		return m.showToast("No links in the last response")
+	case len(found) == 1 && n > 1:
+		return m.showToast("The last response has one link")
+	case n > len(found):
+		return m.showToast(fmt.Sprintf("The last response has only %d links", len(found)))
+	}
+	url := found[n-1]
+	if err := browse(url); err != nil {
+		return m.showToast("Cannot open " + url + ": " + err.Error())
+	}
+	return m.showToast("Opened " + url)
+}
+
+func browse(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
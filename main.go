@@ -2,93 +2,244 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"flag"
 	"fmt"
-	"os/exec"
+	"maps"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/mattn/go-runewidth"
 )
 
-// styles
+// the input box grows and shrinks between these; the transcript keeps at
+// least MIN_TRANSCRIPT_HEIGHT lines
+const (
+	INPUT_MIN_HEIGHT      = 1
+	INPUT_MAX_HEIGHT      = 15
+	MIN_TRANSCRIPT_HEIGHT = 3
+
+	STATUS_HEIGHT = 1
+)
+
+// styles; the colors are filled in from the theme (applyTheme)
 var (
 	appStyle      = lipgloss.NewStyle().Margin(1, 2)
 	viewportStyle = lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("62")).
 			Padding(1, 2)
 
 	textareaStyle = lipgloss.NewStyle().
 			Border(lipgloss.NormalBorder(), false, false, true, false).
-			BorderForeground(lipgloss.Color("240")).
 			Padding(1, 2)
 
-	messageStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("205"))
-
-	botMessageStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("86"))
+	timeStyle          = lipgloss.NewStyle()
+	messageStyle       = lipgloss.NewStyle()
+	botMessageStyle    = lipgloss.NewStyle()
+	systemMessageStyle = lipgloss.NewStyle()
+	focusStyle         = lipgloss.NewStyle() // border and prompt of the pane with the keys
 )
 
 type errMsg error
-type cliResponseMsg string
+type timesTickMsg struct{}
+type cliResponseMsg struct {
+	text    string
+	latency time.Duration
+	request int
+
+	// the context summary the request ended up with
+	summary    string
+	summarized int
+	session    string // see sessionMeta.Resume
+	usage      Usage
+	failed     bool // text says what went wrong
+}
 type pipeMsg string
 type pipeCloseMsg struct{}
+type syncResultMsg struct {
+	result SyncResult
+	err    error
+}
 
 type model struct {
 	viewport   viewport.Model
 	textarea   textarea.Model
-	storage    Storage
-	messages   []string
-	pipe       <-chan string
+	storage    *syncGuard
+	messages   []Message
+	meta       sessionMeta
+	pipe       chan string
 	cliLoading bool
+	spinner    spinner.Model
+	sentAt     time.Time // when the pending request went out, or goes out once the rate limit lets it
+	heardAt    time.Time // when the backend last wrote something
+	request    int       // counts requests, so replies to cancelled ones are dropped
+	cancel     context.CancelFunc
+	ctx        context.Context // ends when relay quits, and with it every request
+	shutdown   context.CancelFunc
+	alert      NotifyConfig // how a response is announced
+	focused    bool         // the terminal window has focus, as far as it reports
+	inline     bool         // no alternate screen; see inline.go
+	printed    []Message    // what inline mode has put in the scrollback
+	stream     chan string
+	partial    string // the response so far, shown until it is complete
+	newBelow   bool   // output arrived while the transcript was scrolled up
+	historyAt  int    // prompts back from the newest; 0 when not recalling
+	recalled   string
+	typedAt    time.Time // last key typed into the input, to tell pastes apart
+	editing    int       // message being edited, counted from 1; 0 when not editing
+	foldLines  int
+	hideLabels bool
+	compact    bool         // no borders or margins; see compact.go
+	links      bool         // the terminal shows OSC 8 hyperlinks
+	expanded   map[int]bool // long responses unfolded with o in select mode
+	complete   completion
+	files      []attachment // attached with /attach for the next message
+	queue      []string     // sent while a response was pending, oldest first
+	chipRows   int
+	refs       []attachment // the @paths of refsInput, see lookupRefs
+	refsInput  string
+	compare    compare
+	syncFirst  bool // sync once the interface is up
+	syncRemote string
 	err        error
 	currentId  uint32
+	mode       mode
+	menu       menu
+	prompt     prompt
+	search     search
+	palette    palette
+	bookmarks  []paletteEntry // listed by the bookmarks overlay, in menu order
+	models     []string       // listed by the models overlay
+	selected   int
+	sidebar    sidebar
+	archived   bool
+	mergeFrom  uint32
+	filter     textinput.Model
+	filtering  bool
+	codeFrom   int // message the last copied code block came from
+	codeIndex  int
+	find       finder
+	help       help
+	pendingG   bool // first g of gg in normal mode
+	toast      string
+	toastId    int
+	notices    []notice
+	keys       keyMap
+	unsaved    bool  // changed since it was last stored, see changed
+	stats      Stats // of the messages, counted when they change
+	offsets    []int // line each message starts on in the viewport, see messageOffset
+	times      timeFormat
+	autoTitle  bool
+	autosave   bool
+	templates  []Template
+	snippets   []Snippet
+	chat       ChatConfig
+	width      int
+	height     int
 }
 
-func initialModel() model {
+func initialModel(cfg Config) model {
 	pipe := make(chan string, 10)
 	ta := textarea.New()
-	ta.Placeholder = "Enter your message here"
+	ta.Placeholder = cfg.UI.Placeholder
 	ta.Focus()
-	ta.Prompt = "| "
-	ta.CharLimit = 2000
+	ta.Prompt = cfg.UI.Prompt
+	ta.CharLimit = 0 // long prompts come from pastes and $EDITOR (ctrl+e)
+	ta.MaxHeight = 0
 	ta.SetWidth(30)
-	ta.SetHeight(3)
-	ta.ShowLineNumbers = true
+	ta.SetHeight(min(max(cfg.UI.InputHeight, INPUT_MIN_HEIGHT), INPUT_MAX_HEIGHT))
+	ta.ShowLineNumbers = cfg.UI.LineNumbers
 	ta.KeyMap.InsertNewline.SetEnabled(true)
+	ta.FocusedStyle.Prompt = focusStyle
+	ta.BlurredStyle.Prompt = menuDetailStyle
 
 	vp := viewport.New(30, 5)
 	vp.SetContent("Chat successfully initialized. Type a message below.")
 
-	storage := &Storage{
-		stdOut: pipe,
-	}
+	storage := &syncGuard{underlying: openStore(cfg.Storage, pipe)}
 
+	// the notices wait in the pipe until the interface is up
 	if err := storage.Initialize(); err != nil {
-		fmt.Println("Error initializing storage:", err)
+		go func() {
+			pipe <- "Error initializing storage: " + err.Error()
+		}()
+	} else if _, err := storage.PurgeTrash(cfg.Storage.TrashGrace()); err != nil {
+		go func() {
+			pipe <- "Error purging trash: " + err.Error()
+		}()
 	}
 
-	return model{
+	ctx, shutdown := context.WithCancel(context.Background())
+	m := model{
+		ctx:        ctx,
+		shutdown:   shutdown,
 		viewport:   vp,
 		textarea:   ta,
-		messages:   []string{},
+		messages:   []Message{},
 		cliLoading: false,
-		storage:    *storage,
+		spinner:    newSpinner(),
+		storage:    storage,
 		pipe:       pipe,
+		syncFirst:  cfg.Sync.OnStartup,
+		syncRemote: cfg.Sync.Remote,
 		err:        nil,
 		currentId:  0,
+		meta:       sessionMeta{Provider: cfg.Chat.Provider, Model: cfg.Chat.Model},
+		autoTitle:  cfg.Session.AutoTitle,
+		autosave:   cfg.Session.Autosave,
+		templates:  cfg.Templates,
+		snippets:   cfg.Snippets,
+		chat:       cfg.Chat,
+		codeFrom:   -1,
+		times:      parseTimeFormat(cfg.UI.Timestamps),
+		foldLines:  cfg.UI.FoldLines,
+		hideLabels: cfg.UI.HideLabels,
+		links:      supportsHyperlinks(),
+		alert:      cfg.Notify,
+		focused:    true,
+		compact:    cfg.UI.Compact,
+		keys:       newKeyMap(cfg.Keys),
 	}
+	m.markSaved()
+
+	switch {
+	case cfg.Session.Open != 0:
+		if err := m.loadSession(cfg.Session.Open); err != nil {
+			m.addMessage(RoleSystem, fmt.Sprintf("Error opening session #%d: %v", cfg.Session.Open, err))
+		}
+	case cfg.Session.ResumeLast:
+		m.resumeLatest()
+	}
+	return m
 }
 
 func (m model) Init() tea.Cmd {
-	return tea.Batch(
+	cmds := []tea.Cmd{
 		textarea.Blink,
 		waitForPipeMsg(m.pipe),
-	)
+		m.checkBackend(),
+	}
+	if m.syncFirst {
+		cmds = append(cmds, m.startSync())
+	}
+	if m.times == timesRelative {
+		cmds = append(cmds, tickTimes())
+	}
+	return tea.Batch(cmds...)
+}
+
+func tickTimes() tea.Cmd {
+	return tea.Tick(time.Minute, func(time.Time) tea.Msg { return timesTickMsg{} })
 }
 
 func waitForPipeMsg(pipe <-chan string) tea.Cmd {
@@ -101,107 +252,448 @@ func waitForPipeMsg(pipe <-chan string) tea.Cmd {
 	}
 }
 
-func messagesToContent(messages []string) Content {
-	var tmp strings.Builder
-	for _, message := range messages {
-		tmp.WriteString(message + "\n")
-	}
-
-	content := bytes.NewBufferString(tmp.String())
-	var contentBytes [4096]byte
-	copy(contentBytes[:], content.Bytes())
+func transcriptToContent(t transcript) Content {
+	content := bytes.NewBuffer(encodeTranscript(t))
 
 	return Content{
 		Id:        0,
 		CreatedAt: time.Now().Unix(),
 		UpdatedAt: time.Now().Unix(),
-		Length:    uint16(content.Len()),
-		Content:   contentBytes,
+		Length:    uint32(content.Len()),
+		Content:   content.Bytes(),
 	}
 }
 
-func saveChatHistoryToFile(id uint32, messages []string, storage *Storage) uint32 {
-	id, err := storage.Store(id, messagesToContent(messages))
+// saveChatHistoryToFile stores t under id, or under a new id when id is 0,
+// and returns the id it is stored under.
+func saveChatHistoryToFile(id uint32, t transcript, storage Store) (uint32, error) {
+	if id != 0 {
+		return id, storage.Update(id, transcriptToContent(t))
+	}
+	return storage.Store(id, transcriptToContent(t))
+}
+
+// save stores the chat. When that fails the chat stays unsaved, so quitting
+// still asks, and the error comes up as a notice.
+func (m *model) save() error {
+	err := m.trySave()
 	if err != nil {
-		fmt.Println("Error saving chat history:", err)
+		pipe := m.pipe
+		go func() {
+			pipe <- "Could not save the chat: " + err.Error()
+		}()
+	}
+	return err
+}
+
+// trySave is save for callers that report the error themselves.
+func (m *model) trySave() error {
+	id, err := saveChatHistoryToFile(m.currentId, m.transcript(), m.storage)
+	if err != nil {
+		return err
+	}
+	m.currentId = id
+	m.markSaved()
+	m.refreshSidebar()
+	return nil
+}
+
+// transcriptOptions are the display settings the viewport content is
+// rendered with.
+func (m model) transcriptOptions() renderOptions {
+	opts := renderOptions{selected: -1, times: m.times, width: m.viewport.Width, fold: m.foldLines, expanded: m.expanded, hideLabels: m.hideLabels, links: m.links}
+	if m.mode == modeSelect {
+		opts.selected = m.selected
+	}
+	if m.mode == modeFind {
+		// matches in folded lines would be found but not shown
+		opts.fold = 0
+	}
+	return opts
+}
+
+func (m *model) refreshTranscript() {
+	opts := m.transcriptOptions()
+	messages := m.messages
+	if m.partial != "" {
+		messages = append(messages[:len(messages):len(messages)], Message{Role: RoleBot, Text: m.partial})
+		// a response is not folded while it is still coming in
+		opts.expanded = map[int]bool{len(messages) - 1: true}
+		maps.Copy(opts.expanded, m.expanded)
+	}
+	content, offsets := layoutTranscript(messages, opts)
+	m.offsets = offsets[:len(m.messages)+1]
+	if m.mode == modeFind {
+		m.find.matches = findMatches(content, m.find.input.Value())
+		m.find.current = min(m.find.current, max(len(m.find.matches)-1, 0))
+		content = highlightMatches(content, m.find.matches, m.find.current)
+	}
+	m.viewport.SetContent(content)
+}
+
+func (m *model) addMessage(role Role, text string) {
+	m.messages = append(m.messages, Message{Role: role, Text: text, Time: time.Now().Unix()})
+	m.changed()
+
+	m.refreshTranscript()
+	m.viewport.GotoBottom()
+}
+
+// undo drops the last user message and everything after it: the bot's
+// reply and any system notes.
+func (m *model) undo() {
+	last := -1
+	for i, message := range m.messages {
+		if message.Role == RoleUser {
+			last = i
+		}
+	}
+	if last < 0 {
+		m.addMessage(RoleSystem, "Nothing to undo")
+		return
+	}
+
+	m.messages = m.messages[:last]
+	m.changed()
+	m.editing = 0
+	m.expanded = nil
+	m.compare.mark = 0
+	m.refreshTranscript()
+	m.viewport.GotoBottom()
+	if m.autosave && m.currentId != 0 {
+		m.save()
 	}
-	return id
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	m, ok := next.(model)
+	if !ok {
+		return next, cmd
+	}
+	if m.mode == modeChat {
+		// however the input changed, the completions and chips follow it
+		m.completeInput()
+		m.lookupRefs()
+		if m.chipsHeight() != m.chipRows {
+			m.resize()
+		}
+	}
+	if m.inline {
+		cmd = tea.Batch(cmd, m.printMessages())
+	}
+	if m.viewport.AtBottom() {
+		m.newBelow = false
+	}
+	return m, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var (
 		tiCmd tea.Cmd
 		vpCmd tea.Cmd
 	)
 
+	// Overlays and app-level shortcuts take the key before the textarea
+	// gets a chance to edit with it.
+	if msg, ok := msg.(tea.MouseMsg); ok {
+		return m.updateMouse(msg)
+	}
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		if m.mode != modeChat {
+			return m.updateOverlay(msg)
+		}
+		if m.sidebar.focused {
+			return m.updateSidebar(msg)
+		}
+		keys := m.keys.Chat
+		switch {
+		case m.cliLoading && key.Matches(msg, keys.Cancel):
+			m.cancelResponse()
+			return m, nil
+		case msg.Paste:
+			m.paste(msg)
+			return m, nil
+		case m.complete.visible() && key.Matches(msg, keys.Complete):
+			m.acceptCompletion()
+			return m, nil
+		case m.complete.visible() && key.Matches(msg, keys.HistoryPrev):
+			m.complete.menu.up()
+			return m, nil
+		case m.complete.visible() && key.Matches(msg, keys.HistoryNext):
+			m.complete.menu.down()
+			return m, nil
+		case m.complete.visible() && key.Matches(msg, keys.Normal):
+			m.dismissCompletion()
+			return m, nil
+		case key.Matches(msg, keys.HistoryPrev) && m.browsingHistory():
+			m.recall(1)
+			return m, nil
+		case key.Matches(msg, keys.HistoryNext) && m.browsingHistory():
+			m.recall(-1)
+			return m, nil
+		case key.Matches(msg, keys.PageUp):
+			m.viewport.PageUp()
+			return m, nil
+		case key.Matches(msg, keys.PageDown):
+			m.viewport.PageDown()
+			return m, nil
+		case key.Matches(msg, keys.Top):
+			m.viewport.GotoTop()
+			return m, nil
+		case key.Matches(msg, keys.Bottom):
+			m.viewport.GotoBottom()
+			return m, nil
+		case key.Matches(msg, keys.Checkpoint):
+			return m.openPrompt(promptCheckpoint, "Checkpoint name:", "")
+		case key.Matches(msg, keys.Checkpoints):
+			return m.openCheckpoints()
+		case key.Matches(msg, keys.NewSession):
+			m.newSession()
+			return m, nil
+		case key.Matches(msg, keys.Templates):
+			return m.openTemplates()
+		case key.Matches(msg, keys.Snippets):
+			return m.openSnippets()
+		case key.Matches(msg, keys.Sessions):
+			if m.cliLoading {
+				return m, nil
+			}
+			return m.openSessions()
+		case key.Matches(msg, keys.Sidebar):
+			return m.toggleSidebar()
+		case key.Matches(msg, keys.Rename):
+			return m.openPrompt(promptRename, "Title:", m.meta.Title)
+		case key.Matches(msg, keys.Select):
+			return m.openSelect()
+		case key.Matches(msg, keys.Jump):
+			return m.openJump()
+		case key.Matches(msg, keys.Find):
+			return m.openFind()
+		case key.Matches(msg, keys.Detach):
+			m.detachLast()
+			return m, nil
+		case key.Matches(msg, keys.Editor):
+			return m.openEditor()
+		case key.Matches(msg, keys.LineNumbers):
+			m.textarea.ShowLineNumbers = !m.textarea.ShowLineNumbers
+			if m.textarea.ShowLineNumbers {
+				return m, m.showToast("Line numbers on")
+			}
+			return m, m.showToast("Line numbers off")
+		case key.Matches(msg, keys.Times):
+			m.times = m.times.next()
+			m.refreshTranscript()
+			toast := m.showToast("Times: " + m.times.String())
+			if m.times == timesRelative {
+				return m, tea.Batch(toast, tickTimes())
+			}
+			return m, toast
+		case key.Matches(msg, keys.Undo):
+			if m.cliLoading || m.storage.syncing {
+				return m, nil
+			}
+			m.undo()
+			return m, nil
+		case key.Matches(msg, keys.Stats):
+			return m.openStats()
+		case key.Matches(msg, keys.Notices):
+			return m.openNotices()
+		case key.Matches(msg, keys.Taller):
+			m.setInputHeight(m.textarea.Height() + 1)
+			return m, nil
+		case key.Matches(msg, keys.Shorter):
+			m.setInputHeight(m.textarea.Height() - 1)
+			return m, nil
+		case key.Matches(msg, keys.CopyResponse):
+			return m, m.copyResponse()
+		case key.Matches(msg, keys.CopyCode):
+			return m, m.copyCodeBlock()
+		case key.Matches(msg, keys.Palette):
+			return m.openPalette()
+		case key.Matches(msg, keys.Bookmarks):
+			return m.openBookmarks()
+		case key.Matches(msg, keys.Compact):
+			return m.toggleCompact()
+		case key.Matches(msg, keys.OpenLink):
+			n, _ := strconv.Atoi(strings.TrimPrefix(msg.String(), "alt+"))
+			return m, m.openLink(n)
+		case key.Matches(msg, keys.Help):
+			return m.openHelp()
+		case key.Matches(msg, keys.Export):
+			m.exportSession()
+			return m, nil
+		}
+	}
+
+	burst := false
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		burst = m.inBurst(msg)
+	}
+
 	m.textarea, tiCmd = m.textarea.Update(msg)
-	m.viewport, vpCmd = m.viewport.Update(msg)
+	// keys that reach here are the textarea's; the transcript only scrolls
+	// with the bindings below or in normal mode
+	if _, ok := msg.(tea.KeyMsg); !ok {
+		m.viewport, vpCmd = m.viewport.Update(msg)
+	}
+	if m.mode == modePrompt {
+		// keep the prompt's cursor blinking
+		var cmd tea.Cmd
+		m.prompt.input, cmd = m.prompt.input.Update(msg)
+		tiCmd = tea.Batch(tiCmd, cmd)
+	} else if m.mode == modeFind {
+		var cmd tea.Cmd
+		m.find.input, cmd = m.find.input.Update(msg)
+		tiCmd = tea.Batch(tiCmd, cmd)
+	} else if m.mode == modeSearch {
+		var cmd tea.Cmd
+		m.search.input, cmd = m.search.input.Update(msg)
+		tiCmd = tea.Batch(tiCmd, cmd)
+	} else if m.filtering {
+		var cmd tea.Cmd
+		m.filter, cmd = m.filter.Update(msg)
+		tiCmd = tea.Batch(tiCmd, cmd)
+	}
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+j", "shift+enter":
+		keys := m.keys.Chat
+		switch {
+		case key.Matches(msg, keys.Newline):
 			// shift+enter 가 ctrl+j 로 들어옴
 			m.textarea.SetValue(m.textarea.Value() + "\n")
-		}
-		switch msg.Type {
-		case tea.KeyCtrlS:
-			id := saveChatHistoryToFile(m.currentId, m.messages, &m.storage)
-			m.currentId = id
-		case tea.KeyCtrlC, tea.KeyEsc:
-			return m, tea.Quit
-		case tea.KeyUp:
-			m.viewport.ScrollUp(1)
-		case tea.KeyDown:
-			m.viewport.ScrollDown(1)
-		case tea.KeyEnter:
-			if m.cliLoading {
+		case key.Matches(msg, keys.Save):
+			if m.storage.syncing {
 				return m, nil
 			}
+			m.save()
+		case key.Matches(msg, keys.Sync):
+			if m.storage.syncing {
+				return m, nil
+			}
+			return m, m.startSync()
+		case key.Matches(msg, keys.Quit):
+			return m.confirmQuit()
+		case key.Matches(msg, keys.Normal, keys.Focus):
+			return m.openNormal()
+		case key.Matches(msg, keys.Send):
+			if burst {
+				// the textarea already took it as a newline
+				return m, tiCmd
+			}
 
-			userInput := m.textarea.Value()
+			userInput := strings.TrimSpace(m.textarea.Value())
+			m.historyAt = 0
 			if userInput == "" {
+				m.editing = 0
+				m.textarea.Reset()
 				return m, nil
 			}
-
-			m.messages = append(m.messages, messageStyle.Render("User : ")+userInput)
-
-			m.viewport.SetContent(strings.Join(m.messages, "\n"))
-			m.viewport.GotoBottom()
+			if m.cliLoading {
+				m.enqueue(userInput)
+				return m, tiCmd
+			}
 
 			m.textarea.Reset()
-			m.cliLoading = true
-
-			return m, tea.Batch(tiCmd, runChatCommand(userInput))
+			return m.submit(userInput, tiCmd)
 		}
 	case cliResponseMsg:
-		m.cliLoading = false
-		response := string(msg)
-
-		m.messages = append(m.messages, botMessageStyle.Render("Bot : ")+response)
-		m.messages = append(m.messages, "")
-
-		m.viewport.SetContent(strings.Join(m.messages, "\n"))
-		m.viewport.GotoBottom()
+		if msg.request != m.request || !m.cliLoading {
+			return m, tea.Batch(tiCmd, vpCmd)
+		}
+		m.stopLoading()
+		m.stream, m.partial = nil, ""
+		response := strings.TrimRight(msg.text, "\n")
+
+		if msg.failed {
+			// a note, so it is not sent back as the bot's turn
+			m.addMessage(RoleSystem, response)
+		} else {
+			m.addMessage(RoleBot, response)
+			m.messages[len(m.messages)-1].Latency = msg.latency.Milliseconds()
+			m.messages[len(m.messages)-1].Usage = msg.usage
+			m.meta.Summary, m.meta.Summarized = msg.summary, msg.summarized
+			m.keepResume(msg.session)
+			m.changed()
+		}
+		m.alertResponse(response, msg.latency)
+		// while syncing the store is busy; the chat is saved once it is done
+		if m.autosave && !m.storage.syncing {
+			m.save()
+		}
 
-		return m, tea.Batch(tiCmd, vpCmd)
+		if m.wantsTitle() {
+			tiCmd = tea.Batch(tiCmd, generateTitle(m.ctx, m.meta, m.messages))
+		}
+		return m.sendQueued(tea.Batch(tiCmd, vpCmd))
+	case streamMsg:
+		// chunks left over from a finished response are drained and dropped
+		if msg.stream == m.stream {
+			m.appendPartial(msg.text)
+		}
+		return m, tea.Batch(tiCmd, vpCmd, waitForStream(msg.stream))
+	case spinner.TickMsg:
+		if m.cliLoading {
+			var cmd tea.Cmd
+			m.spinner, cmd = m.spinner.Update(msg)
+			return m, tea.Batch(tiCmd, vpCmd, cmd)
+		}
+	case tea.FocusMsg:
+		m.focused = true
+	case tea.BlurMsg:
+		m.focused = false
+	case toastExpiredMsg:
+		if msg.id == m.toastId {
+			m.toast = ""
+		}
+	case editorDoneMsg:
+		return m, tea.Batch(tiCmd, vpCmd, m.applyEditor(msg))
+	case modelsMsg:
+		var cmd tea.Cmd
+		m, cmd = m.applyModels(msg)
+		return m, tea.Batch(tiCmd, vpCmd, cmd)
+	case retryMsg:
+		if msg.request == m.request && m.cliLoading {
+			return m, tea.Batch(tiCmd, vpCmd, m.applyRetry(msg))
+		}
+	case summaryMsg:
+		if msg.request == m.request && m.cliLoading {
+			m.applySummary(msg)
+			return m.sendQueued(tea.Batch(tiCmd, vpCmd))
+		}
+	case timesTickMsg:
+		// relative times go stale; redraw them while they are shown
+		if m.times == timesRelative {
+			m.refreshTranscript()
+			return m, tea.Batch(tiCmd, vpCmd, tickTimes())
+		}
+	case titleMsg:
+		m.applyTitle(msg)
+	case backendCheckMsg:
+		return m, tea.Batch(tiCmd, vpCmd, m.applyBackendCheck(msg))
 	case tea.WindowSizeMsg:
-		headerHeight := 0
-		footerHeight := 6
-		varticalMarginHeight := headerHeight + footerHeight
-
-		m.viewport.Width = msg.Width - 4
-		m.viewport.Height = msg.Height - varticalMarginHeight
-
-		m.textarea.SetWidth(msg.Width - 4)
+		m.width = msg.Width
+		m.height = msg.Height
+		m.resize()
 	case pipeMsg:
-		m.messages = append(m.messages, messageStyle.Render("System : ")+string(msg))
-		m.messages = append(m.messages, "")
-
-		m.viewport.SetContent(strings.Join(m.messages, "\n"))
-		m.viewport.GotoBottom()
+		return m, tea.Batch(m.notify(string(msg)), waitForPipeMsg(m.pipe))
 
-		return m, waitForPipeMsg(m.pipe)
+	case syncResultMsg:
+		m.storage.syncing = false
+		if moved, ok := msg.result.Moved[m.currentId]; ok {
+			// another machine's chat has the id now
+			m.currentId = moved
+		}
+		text := "Sync finished: " + msg.result.String()
+		if msg.err != nil {
+			text = "Sync failed: " + msg.err.Error()
+		}
+		if m.autosave && m.dirty() {
+			m.save()
+		}
+		m.refreshSidebar()
+		return m, tea.Batch(tiCmd, vpCmd, m.notify(text))
 
 	case errMsg:
 		m.err = msg
@@ -210,50 +702,319 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(tiCmd, vpCmd)
 }
 
+func (m *model) resize() {
+	if m.width == 0 {
+		return
+	}
+
+	// frames (margin, border, padding) are subtracted so the boxes fit the
+	// terminal exactly instead of wrapping at the right edge
+	innerWidth := m.width - appStyle.GetHorizontalFrameSize()
+	headerHeight := 0
+	m.chipRows = m.chipsHeight()
+	footerHeight := m.textarea.Height() + STATUS_HEIGHT + m.completionHeight() + m.chipRows
+	varticalMarginHeight := headerHeight + footerHeight + appStyle.GetVerticalFrameSize() + viewportStyle.GetVerticalFrameSize()
+
+	m.viewport.Width = innerWidth - viewportStyle.GetHorizontalFrameSize()
+	if m.sidebar.visible {
+		m.viewport.Width -= SIDEBAR_WIDTH
+	}
+	m.viewport.Height = m.height - varticalMarginHeight
+
+	m.textarea.SetWidth(innerWidth)
+
+	// re-wrap for the new width; an empty chat keeps its greeting
+	if len(m.messages) > 0 || m.partial != "" {
+		m.refreshTranscript()
+	}
+}
+
+// setInputHeight resizes the input box within its limits, giving the lines
+// to or taking them from the transcript.
+func (m *model) setInputHeight(height int) {
+	height = min(max(height, INPUT_MIN_HEIGHT), INPUT_MAX_HEIGHT)
+	if height > m.textarea.Height() && m.width != 0 && m.viewport.Height <= MIN_TRANSCRIPT_HEIGHT {
+		return
+	}
+	m.textarea.SetHeight(height)
+	m.resize()
+}
+
 func (m model) View() string {
 	if m.err != nil {
 		return fmt.Sprintf("\nError: %v\n", m.err)
 	}
 
 	// 뷰포트 렌더링 (스타일 적용)
-	chatBox := viewportStyle.Render(m.viewport.View())
+	frame := m.frameStyle()
+	chatBox := frame.Render(m.viewport.View())
+	if !m.compact {
+		chatBox = titledBorder(chatBox, m.sessionTitle(), frame)
+		chatBox = toastBorder(chatBox, m.toast, m.scrollPosition(), frame)
+	}
+	if m.mode.covers() {
+		chatBox = m.overlayView()
+	}
+	inlineChat := m.inline && m.mode == modeChat && !m.sidebar.visible
+	if inlineChat {
+		// the transcript is in the scrollback
+		chatBox = m.inlineView()
+	}
+	if m.sidebar.visible {
+		chatBox = lipgloss.JoinHorizontal(lipgloss.Top, m.sidebarView(lipgloss.Height(chatBox)), chatBox)
+	}
 
 	// 입력창 렌더링
 	inputBox := m.textarea.View()
 
-	// 로딩 표시
-	if m.cliLoading {
-		inputBox = "Thinking..."
+	if m.mode == modePrompt {
+		inputBox = m.prompt.input.View()
+	}
+	if m.mode == modeSelect {
+		keys := m.keys.Select
+		inputBox = hint(keys.Fork, keys.Edit, keys.Reuse, keys.Delete, keys.Help, keys.Back)
+	}
+	if m.mode == modeFind {
+		inputBox = m.findBar()
+	}
+	if m.mode == modeQuit {
+		inputBox = m.quitView()
+	}
+	if m.mode == modeNormal {
+		keys := m.keys.Normal
+		inputBox = menuTitleStyle.Render("-- NORMAL --") + "  " + hint(keys.Top, keys.Bottom, keys.Find, keys.Select, keys.Insert, keys.Help)
 	}
 
+	width := lipgloss.Width(chatBox)
+	if inlineChat {
+		width = m.width - appStyle.GetHorizontalFrameSize()
+	}
+	status := m.statusBar(width)
+	if m.completionHeight() > 0 {
+		status += "\n" + m.completionView(width)
+	}
+	if m.chipRows > 0 {
+		status += "\n" + m.chipsView(width)
+	}
+
+	if inlineChat {
+		if chatBox == "" {
+			return appStyle.Render(status + "\n" + inputBox)
+		}
+		return appStyle.Render(chatBox + "\n" + status + "\n" + inputBox)
+	}
 	return appStyle.Render(fmt.Sprintf(
-		"%s\n%s",
+		"%s\n%s\n%s",
 		chatBox,
+		status,
 		inputBox,
 	))
 }
 
+// sessionTitle is shown in the transcript's top border.
+func (m model) sessionTitle() string {
+	switch {
+	case m.currentId == 0 && m.meta.Title == "":
+		return "New chat"
+	case m.currentId == 0:
+		return m.meta.Title
+	case m.meta.Title == "":
+		return fmt.Sprintf("#%d", m.currentId)
+	}
+	return fmt.Sprintf("#%d %s", m.currentId, m.meta.Title)
+}
+
+// frameStyle is the transcript's box. Its border lights up while the keys
+// go to the transcript rather than the input.
+func (m model) frameStyle() lipgloss.Style {
+	switch m.mode {
+	case modeNormal, modeSelect, modeFind:
+		return viewportStyle.BorderForeground(focusStyle.GetForeground())
+	}
+	return viewportStyle
+}
+
+// titledBorder writes title into the top edge of a box rendered with frame.
+func titledBorder(box, title string, frame lipgloss.Style) string {
+	top, rest, ok := strings.Cut(box, "\n")
+	if !ok {
+		return box
+	}
+	width := lipgloss.Width(top)
+	if width < 6 {
+		return box
+	}
+
+	border := lipgloss.RoundedBorder()
+	label := " " + ansi.Truncate(title, width-6, "…") + " "
+	fill := strings.Repeat(border.Top, width-3-lipgloss.Width(label))
+	borderStyle := lipgloss.NewStyle().Foreground(frame.GetBorderTopForeground())
+	top = borderStyle.Render(border.TopLeft+border.Top) + menuTitleStyle.Render(label) + borderStyle.Render(fill+border.TopRight)
+	return top + "\n" + rest
+}
+
+// submit runs a slash command or sends the input to the backend, along with
+// whatever is attached to it.
+func (m model) submit(userInput string, cmd tea.Cmd) (model, tea.Cmd) {
+	if strings.HasPrefix(userInput, "/") {
+		m, slashCmd := m.runSlashCommand(userInput)
+		return m, tea.Batch(cmd, slashCmd)
+	}
+
+	files := m.attached(userInput)
+	prompt, err := withAttachments(userInput, files)
+	if err != nil {
+		return m, tea.Batch(cmd, m.showToast("Cannot attach: "+err.Error()))
+	}
+
+	m.resend()
+	m.addMessage(RoleUser, userInput)
+	m.messages[len(m.messages)-1].Attachments = attachmentNames(files)
+	m.files = nil
+
+	m.stream = make(chan string, 64)
+	m.partial = ""
+	ctx, loading := m.startLoading()
+	req := m.chatRequest(prompt)
+	req.notBefore = reserveSlot(req.provider, m.sentAt)
+	m.sentAt, m.heardAt = req.notBefore, req.notBefore
+	return m, tea.Batch(cmd, loading, runChatCommand(ctx, m.request, req, m.stream), waitForStream(m.stream))
+}
+
 // --- 6. 외부 명령 실행 함수 (Integration) ---
 // 실제 ClaudeCode나 Gemini CLI를 여기서 호출합니다.
-func runChatCommand(input string) tea.Cmd {
+func runChatCommand(ctx context.Context, request int, req chatRequest, stream chan<- string) tea.Cmd {
 	return func() tea.Msg {
-		// [실제 연동 방법]
-		// cmd := exec.Command("claude", "p", input) // 예시
-		cmd := exec.Command("echo", "Simulated AI Response to: "+input)
+		defer close(stream)
+
+		select {
+		case <-time.After(time.Until(req.notBefore)):
+		case <-ctx.Done():
+			return cliResponseMsg{text: "Error executing command: " + ctx.Err().Error(), request: request, failed: true}
+		}
+
+		dropped, kept := req.fit()
+		if err := req.summarize(ctx, dropped); err != nil {
+			// the history that fits still goes out; only the summary is stale
+			req.strategy = "truncate"
+		}
+
+		start := time.Now()
+		var out, session string
+		var usage Usage
+		var err error
+		prompt := req.prompt(dropped, kept)
+		if isAPI(req.provider) {
+			var reply apiReply
+			reply, err = streamAPI(ctx, req.provider, req.conversation(dropped, kept), stream)
+			out, session, usage = reply.text, reply.session, reply.usage
+		} else {
+			out, err = streamBackend(ctx, req.provider, req.model, prompt, req.params, stream)
+		}
+		if usage.Total() == 0 {
+			usage = estimatedUsage(prompt, out)
+		}
+		if usage.Cost == 0 {
+			usage.Cost = exchangeCost(req.provider, req.model, usage)
+		}
+		if err != nil && out == "" && ctx.Err() == nil && req.retries > req.attempt && transient(err) {
+			return req.retry(ctx, request, err)
+		}
+		if err != nil {
+			return cliResponseMsg{text: "Error executing command: " + err.Error(), latency: time.Since(start), request: request, failed: true}
+		}
+
+		return cliResponseMsg{text: out, latency: time.Since(start), request: request, summary: req.summary, summarized: req.summarized, session: session, usage: usage}
+	}
+}
 
-		out, err := cmd.CombinedOutput()
+// startSync syncs the store in the background. Until it is done the
+// interface cannot write to the store, see syncGuard.
+func (m model) startSync() tea.Cmd {
+	m.storage.syncing = true
+	return runSync(m.storage.underlying, m.syncRemote, m.pipe)
+}
+
+func runSync(local Store, remoteSpec string, stdOut chan string) tea.Cmd {
+	return func() tea.Msg {
+		remote, err := openRemoteStore(remoteSpec, stdOut)
 		if err != nil {
-			return cliResponseMsg("Error executing command: " + err.Error())
+			return syncResultMsg{err: err}
+		}
+		if err := remote.Initialize(); err != nil {
+			return syncResultMsg{err: err}
 		}
 
-		return cliResponseMsg(string(out))
+		result, err := Sync(local, remote)
+		return syncResultMsg{result: result, err: err}
 	}
 }
 
 func main() {
-	p := tea.NewProgram(initialModel(), tea.WithAltScreen())
+	session := flag.Uint("session", 0, "open the stored session with this id")
+	last := flag.Bool("last", false, "open the most recently updated session")
+	importPath := flag.String("import", "", "import a ChatGPT or Claude.ai conversations.json export and exit")
+	plain := flag.Bool("plain", false, "print the conversation as plain text instead of the full-screen interface")
+	inline := flag.Bool("inline", false, "run in the normal screen, leaving the chat in the scrollback")
+	flag.Parse()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Println("Error loading config:", err)
+	}
+	if *importPath != "" {
+		storage := openStore(cfg.Storage, make(chan string))
+		if err := storage.Initialize(); err != nil {
+			fmt.Println("Error initializing storage:", err)
+			os.Exit(1)
+		}
+		count, err := importFile(storage, *importPath)
+		fmt.Printf("Imported %d conversations\n", count)
+		if err != nil {
+			fmt.Println("Error importing:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *last {
+		cfg.Session.ResumeLast = true
+	}
+	if err := registerCommands(cfg.Chat.Commands); err != nil {
+		fmt.Println("Error in chat.commands:", err)
+	}
+	if err := setFilters(cfg.Chat); err != nil {
+		fmt.Println("Error in chat.filters:", err)
+	}
+	setTimeouts(cfg.Chat)
+	setPrices(cfg.Chat)
+	setRateLimits(cfg.Chat)
+	cfg.Session.Open = uint32(*session)
+	if *plain || cfg.UI.Plain {
+		runPlain(cfg)
+		return
+	}
+
+	// CJK locales make go-runewidth (used by the textarea) count ambiguous
+	// characters such as box drawing and "…" as two cells, while lipgloss
+	// counts them as one; measure them the same way everywhere so the
+	// borders line up
+	runewidth.DefaultCondition.EastAsianWidth = false
+	loadTheme(cfg.UI.Theme, cfg.UI.Background, cfg.Themes)
+	applyRoles(cfg.UI.Roles)
+	setCompact(cfg.UI.Compact)
+
+	m := initialModel(cfg)
+	options := []tea.ProgramOption{tea.WithReportFocus()}
+	if *inline {
+		// the mouse is left to the terminal, for scrolling and selecting
+		m.inline = true
+	} else {
+		options = append(options, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	}
+	p := tea.NewProgram(m, options...)
 
 	if _, err := p.Run(); err != nil {
 		fmt.Println("Error running program:", err)
 	}
+	m.stopBackends()
 }
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const (
+	FOLD_PREVIEW_LINES = 8  // how much of a folded response stays visible
+	MIN_INDENTED_WIDTH = 20 // narrower than this, messages wrap to the left edge
+)
+
+type Role byte
+
+const (
+	RoleUser Role = iota + 1
+	RoleBot
+	RoleSystem
+)
+
+func (r Role) Label() string {
+	switch r {
+	case RoleUser:
+		return "User"
+	case RoleBot:
+		return "Bot"
+	default:
+		return "System"
+	}
+}
+
+type Message struct {
+	Role Role   `json:"role"`
+	Text string `json:"text"`
+	Time int64  `json:"time,omitempty"` // unix seconds; zero for older chats
+
+	Latency     int64    `json:"latency_ms,omitempty"`  // how long the backend took, for bot messages
+	Usage       Usage    `json:"usage,omitzero"`        // tokens the exchange took, for bot messages
+	Attachments []string `json:"attachments,omitempty"` // files sent along with a user message
+
+	Pinned     bool `json:"pinned,omitempty"`     // system message sent as context (summaries)
+	Collapsed  bool `json:"collapsed,omitempty"`  // folded into a summary; hidden and not sent
+	Bookmarked bool `json:"bookmarked,omitempty"` // listed with alt+b
+}
+
+// transcript is the payload stored in a record's Content. Checkpoints set
+// Label, point Parent at the chat they were taken from and keep how far
+// it was scrolled in Offset.
+type transcript struct {
+	Messages []Message `json:"messages"`
+	sessionMeta
+	Label  string `json:"label,omitempty"`
+	Parent uint32 `json:"parent,omitempty"`
+	Offset int    `json:"offset,omitempty"`
+}
+
+// sessionMeta is what a session stores besides its messages.
+type sessionMeta struct {
+	Title    string   `json:"title,omitempty"`
+	Archived bool     `json:"archived,omitempty"`
+	Pinned   bool     `json:"pinned,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	ForkOf   uint32   `json:"fork_of,omitempty"`  // session this one was forked from
+	Template string   `json:"template,omitempty"` // template the session was started from
+	System   string   `json:"system,omitempty"`   // system prompt sent with every message
+	Provider string   `json:"provider,omitempty"` // backend the session talks to
+	Model    string   `json:"model,omitempty"`
+	Params   Params   `json:"params,omitzero"`    // over the configured ones
+	Imported string   `json:"imported,omitempty"` // "chatgpt" or "claude.ai" for imported chats
+
+	// Summary replaces the first Summarized user/bot messages when the
+	// history no longer fits the context budget. It is dropped when
+	// messages are taken out of the history or merged in between.
+	Summary    string `json:"summary,omitempty"`
+	Summarized int    `json:"summarized,omitempty"`
+
+	// Resume is the backend's own id for the conversation, for backends
+	// that keep it (claude-code); it holds while the chat has ResumeAt user
+	// and bot messages, and the next message goes alone.
+	Resume   string `json:"resume,omitempty"`
+	ResumeAt int    `json:"resume_at,omitempty"`
+}
+
+func encodeTranscript(t transcript) []byte {
+	data, _ := json.Marshal(t)
+	return data
+}
+
+// decodeTranscript reads a record payload. Records saved before roles were
+// stored hold the rendered transcript, so the labels are parsed back out.
+func decodeTranscript(payload []byte) transcript {
+	var t transcript
+	if err := json.Unmarshal(payload, &t); err == nil {
+		return t
+	}
+	return transcript{Messages: parseLegacyTranscript(string(payload))}
+}
+
+func parseLegacyTranscript(text string) []Message {
+	labels := []Role{RoleUser, RoleBot, RoleSystem}
+
+	var messages []Message
+	for _, line := range strings.Split(ansi.Strip(text), "\n") {
+		matched := false
+		for _, role := range labels {
+			if rest, ok := strings.CutPrefix(line, role.Label()+" : "); ok {
+				messages = append(messages, Message{Role: role, Text: rest})
+				matched = true
+				break
+			}
+		}
+		if !matched && len(messages) > 0 {
+			last := &messages[len(messages)-1]
+			last.Text += "\n" + line
+		}
+	}
+
+	for i := range messages {
+		messages[i].Text = strings.TrimRight(messages[i].Text, "\n")
+	}
+	return messages
+}
+
+func roleStyle(role Role) lipgloss.Style {
+	switch role {
+	case RoleBot:
+		return botMessageStyle
+	case RoleSystem:
+		return systemMessageStyle
+	}
+	return messageStyle
+}
+
+// RoleConfig changes how a role is labeled in the transcript. Empty fields
+// keep "User", "Bot" and "System" in the theme's colors.
+type RoleConfig struct {
+	Label string `json:"label"`
+	Icon  string `json:"icon"`  // put in front of the label, e.g. a nerd-font glyph
+	Color Color  `json:"color"` // overrides the theme
+}
+
+type RolesConfig struct {
+	User   RoleConfig `json:"user"`
+	Bot    RoleConfig `json:"bot"`
+	System RoleConfig `json:"system"`
+}
+
+// roleLabels are the labels shown in the transcript; Role.Label stays the
+// name used in exports and prompts.
+var roleLabels = map[Role]string{}
+
+// applyRoles sets the labels and colors from the config. It runs after the
+// theme so its colors win.
+func applyRoles(roles RolesConfig) {
+	for role, rc := range map[Role]RoleConfig{RoleUser: roles.User, RoleBot: roles.Bot, RoleSystem: roles.System} {
+		roleLabels[role] = strings.TrimSpace(rc.Icon + " " + cmp.Or(rc.Label, role.Label()))
+		if rc.Color == (Color{}) {
+			continue
+		}
+		switch role {
+		case RoleUser:
+			messageStyle = messageStyle.Foreground(rc.Color.terminal())
+		case RoleBot:
+			botMessageStyle = botMessageStyle.Foreground(rc.Color.terminal())
+		case RoleSystem:
+			systemMessageStyle = systemMessageStyle.Foreground(rc.Color.terminal())
+		}
+	}
+}
+
+func roleLabel(role Role) string {
+	return cmp.Or(roleLabels[role], role.Label())
+}
+
+// timeFormat is how message times are shown in front of each message.
+type timeFormat int
+
+const (
+	timesOff      timeFormat = iota
+	timesRelative            // "5m ago"
+	timesClock               // "14:05"
+)
+
+var timeFormatNames = []string{"off", "relative", "clock"}
+
+func parseTimeFormat(name string) timeFormat {
+	for i, known := range timeFormatNames {
+		if name == known {
+			return timeFormat(i)
+		}
+	}
+	return timesOff
+}
+
+func (f timeFormat) String() string {
+	return timeFormatNames[f]
+}
+
+// next is the format ctrl+t switches to.
+func (f timeFormat) next() timeFormat {
+	return (f + 1) % timeFormat(len(timeFormatNames))
+}
+
+func (f timeFormat) format(now, t time.Time) string {
+	if f == timesRelative {
+		return relativeTime(now, t)
+	}
+	switch {
+	case t.YearDay() == now.YearDay() && t.Year() == now.Year():
+		return t.Format("15:04")
+	case t.Year() == now.Year():
+		return t.Format("Jan 2 15:04")
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// renderOptions are the display toggles applied to the transcript.
+type renderOptions struct {
+	selected int        // message whose label is highlighted, -1 for none
+	times    timeFormat // show when each message was sent
+	width    int        // wrap lines to this many cells; 0 leaves them long
+
+	hideLabels bool // mark messages with a bar in the role's color instead
+	links      bool // make URLs in responses clickable (OSC 8)
+
+	fold     int          // bot messages over this many lines are folded; 0 never folds
+	expanded map[int]bool // folded messages shown in full anyway
+}
+
+// renderTranscript builds the viewport content. Bot and system messages are
+// followed by a blank line so each exchange reads as a block.
+func renderTranscript(messages []Message, opts renderOptions) string {
+	content, _ := layoutTranscript(messages, opts)
+	return content
+}
+
+// layoutTranscript is renderTranscript that also returns the line each
+// message starts on, and after them the line below the last one.
+func layoutTranscript(messages []Message, opts renderOptions) (string, []int) {
+	now := time.Now()
+	lines := make([]string, 0, len(messages)*2)
+	offsets := make([]int, 0, len(messages)+1)
+	height := 0
+	add := func(entries ...string) {
+		for _, entry := range entries {
+			lines = append(lines, entry)
+			height += strings.Count(entry, "\n") + 1
+		}
+	}
+	for i, message := range messages {
+		offsets = append(offsets, height)
+		if message.Collapsed {
+			// one line stands for a run of collapsed messages
+			if i == 0 || !messages[i-1].Collapsed {
+				run := 0
+				for _, next := range messages[i:] {
+					if !next.Collapsed {
+						break
+					}
+					run++
+				}
+				add(timeStyle.Render(fmt.Sprintf("⋯ %d earlier messages summarized", run)), "")
+			}
+			continue
+		}
+
+		style := roleStyle(message.Role)
+		if i == opts.selected {
+			style = style.Reverse(true)
+		}
+		label := style.Render(roleLabel(message.Role))
+		separator := style.Render(" : ")
+		if opts.hideLabels {
+			// a colored bar still tells the roles apart
+			label, separator = style.Render("▍"), " "
+		}
+		if message.Pinned {
+			label += timeStyle.Render(" (pinned)")
+		}
+		if message.Bookmarked {
+			label += focusStyle.Render(" ★")
+		}
+		if opts.times != timesOff && message.Time != 0 {
+			label = timeStyle.Render(opts.times.format(now, time.Unix(message.Time, 0))) + " " + label
+		}
+		// continuation lines hang under the text, clear of the label,
+		// unless that leaves too narrow a column
+		prefix := label + separator
+		indent := lipgloss.Width(prefix)
+		if opts.width > 0 && opts.width-indent < MIN_INDENTED_WIDTH {
+			indent = 0
+		}
+		width := opts.width
+		if width > 0 {
+			width -= indent
+		}
+
+		text := message.Text
+		if message.Role == RoleBot {
+			text = renderResponse(text, width)
+			if opts.fold > 0 && !opts.expanded[i] {
+				text = foldText(text, opts.fold)
+			}
+			if opts.links {
+				text = linkify(text)
+			}
+		}
+		if len(message.Attachments) > 0 {
+			text += "\n" + timeStyle.Render("attached: "+strings.Join(message.Attachments, ", "))
+		}
+		var line string
+		switch {
+		case indent == 0:
+			line = ansi.Wrap(prefix+text, opts.width, "")
+		case width > 0:
+			// words move to the next line whole; only words longer than
+			// the line are broken
+			line = prefix + hangIndent(ansi.Wrap(text, width, ""), indent)
+		default:
+			line = prefix + hangIndent(text, indent)
+		}
+		if opts.links {
+			line = closeLinks(line)
+		}
+		add(line)
+		if message.Role != RoleUser {
+			add("")
+		}
+	}
+	return strings.Join(lines, "\n"), append(offsets, height)
+}
+
+// hangIndent indents every line of text but the first.
+func hangIndent(text string, indent int) string {
+	lines := strings.Split(text, "\n")
+	pad := strings.Repeat(" ", indent)
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = pad + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// foldText cuts text longer than fold lines down to its first few and says
+// how much is hidden.
+func foldText(text string, fold int) string {
+	lines := strings.Split(text, "\n")
+	if len(lines) <= fold {
+		return text
+	}
+	shown := min(FOLD_PREVIEW_LINES, fold)
+	more := timeStyle.Render(fmt.Sprintf("⋯ +%d lines, o expands (ctrl+l to select)", len(lines)-shown))
+	return strings.Join(lines[:shown], "\n") + "\n" + more
+}
+
+// relativeTime reads like "5m ago" for the last day and falls back to the
+// date after that.
+func relativeTime(now, t time.Time) string {
+	switch d := now.Sub(t); {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case t.Year() == now.Year():
+		return t.Format("Jan 2 15:04")
+	}
+	return t.Format("2006-01-02 15:04")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLegacyTranscript(t *testing.T) {
+	tests := []struct {
+		text     string
+		messages []Message
+	}{
+		{"", nil},
+		{"no label yet\n", nil},
+		{
+			"User : hi\nBot : hello\nthere\n\nSystem : note\n",
+			[]Message{{Role: RoleUser, Text: "hi"}, {Role: RoleBot, Text: "hello\nthere"}, {Role: RoleSystem, Text: "note"}},
+		},
+		{
+			"\x1b[1mUser : \x1b[0mbold\nUser: not a label\n",
+			[]Message{{Role: RoleUser, Text: "bold\nUser: not a label"}},
+		},
+		{
+			"Bot : a\n\n  indented\nBot : b",
+			[]Message{{Role: RoleBot, Text: "a\n\n  indented"}, {Role: RoleBot, Text: "b"}},
+		},
+	}
+	for _, tt := range tests {
+		if messages := parseLegacyTranscript(tt.text); !reflect.DeepEqual(messages, tt.messages) {
+			t.Errorf("parseLegacyTranscript(%q) = %+v, want %+v", tt.text, messages, tt.messages)
+		}
+	}
+}
+
+func TestDecodeTranscript(t *testing.T) {
+	stored := transcript{Messages: []Message{{Role: RoleUser, Text: "hi", Time: 5}}, sessionMeta: sessionMeta{Title: "greeting"}}
+	decoded := decodeTranscript(encodeTranscript(stored))
+	if decoded.Title != "greeting" || !reflect.DeepEqual(decoded.Messages, stored.Messages) {
+		t.Errorf("decoded %+v, want %+v", decoded, stored)
+	}
+
+	legacy := decodeTranscript([]byte("User : hi\nBot : hello"))
+	if len(legacy.Messages) != 2 || legacy.Messages[1].Text != "hello" {
+		t.Errorf("a rendered transcript decodes to %+v", legacy.Messages)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+const (
+	// Version 1 stored every chat in a fixed-size slot at id * CONTENT_SIZE.
+	MAXIMUM_MESSAGE_SIZE = 4096
+	CONTENT_SIZE         = 22 + MAXIMUM_MESSAGE_SIZE
+
+	// Version 2 records had no DeletedAt.
+	V2_RECORD_HEADER_SIZE = 24
+)
+
+// migrate rewrites an older database in the current format. The original
+// is kept next to it as chat.db.v<N>.
+func (s *Storage) migrate() error {
+	path := s.dbPath()
+	file, err := os.OpenFile(path, os.O_RDONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	from := s.header.Version
+	var records []Content
+	switch from {
+	case 1:
+		records = readV1Records(file)
+	case 2:
+		records, err = readV2Records(file)
+	default:
+		err = fmt.Errorf("unknown database version %d", from)
+	}
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	backup := fmt.Sprintf("%s.v%d", path, from)
+	if err := copyFile(path, backup); err != nil {
+		return err
+	}
+	if err := s.rewriteLog(records); err != nil {
+		return err
+	}
+
+	go func() {
+		s.stdOut <- fmt.Sprintf("Migrated %d records to database version %d", len(records), VERSION)
+	}()
+	return nil
+}
+
+func readV1Records(file *os.File) []Content {
+	var records []Content
+	buffer := make([]byte, CONTENT_SIZE)
+	for id := uint32(1); ; id++ {
+		offset := int64(HEADER_SIZE) + int64(id)*CONTENT_SIZE
+		if _, err := file.ReadAt(buffer, offset); err != nil {
+			break
+		}
+		if binary.BigEndian.Uint32(buffer[:4]) != id {
+			continue
+		}
+
+		length := min(binary.BigEndian.Uint16(buffer[20:22]), MAXIMUM_MESSAGE_SIZE)
+		records = append(records, Content{
+			Id:        id,
+			CreatedAt: int64(binary.BigEndian.Uint64(buffer[4:12])),
+			UpdatedAt: int64(binary.BigEndian.Uint64(buffer[12:20])),
+			Length:    uint32(length),
+			Content:   append([]byte(nil), buffer[22:22+int(length)]...),
+		})
+	}
+	return records
+}
+
+func readV2Records(file *os.File) ([]Content, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Content
+	buffer := make([]byte, V2_RECORD_HEADER_SIZE)
+	for offset := int64(HEADER_SIZE); offset+V2_RECORD_HEADER_SIZE <= info.Size(); {
+		if _, err := file.ReadAt(buffer, offset); err != nil {
+			return nil, err
+		}
+		length := binary.BigEndian.Uint32(buffer[20:24])
+		if length > MAXIMUM_RECORD_SIZE || offset+V2_RECORD_HEADER_SIZE+int64(length) > info.Size() {
+			break
+		}
+
+		payload := make([]byte, length)
+		if _, err := file.ReadAt(payload, offset+V2_RECORD_HEADER_SIZE); err != nil {
+			return nil, err
+		}
+		records = append(records, Content{
+			Id:        binary.BigEndian.Uint32(buffer[:4]),
+			CreatedAt: int64(binary.BigEndian.Uint64(buffer[4:12])),
+			UpdatedAt: int64(binary.BigEndian.Uint64(buffer[12:20])),
+			Length:    length,
+			Content:   payload,
+		})
+		offset += V2_RECORD_HEADER_SIZE + int64(length)
+	}
+	return records, nil
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// /models lists what the chat's provider can run and switches to the one
+// picked. Providers that can say which models they have are asked; the
+// rest offer the names relay knows.
+
+// MODELS_TIMEOUT bounds asking a provider for its models.
+const MODELS_TIMEOUT = 5 * time.Second
+
+// modelListers ask a provider which models it has.
+var modelListers = map[string]func(ctx context.Context) ([]string, error){
+	"openai":    openaiModels,
+	"anthropic": anthropicModels,
+	"ollama":    ollamaModels,
+}
+
+type modelsMsg struct {
+	provider string
+	models   []string
+	err      error
+}
+
+func (m model) listModels() (model, tea.Cmd) {
+	list, ok := modelListers[m.meta.Provider]
+	if !ok {
+		return m.openModels(m.knownModels())
+	}
+	provider, ctx := m.meta.Provider, m.ctx
+	return m, func() tea.Msg {
+		ctx, cancel := context.WithTimeout(ctx, MODELS_TIMEOUT)
+		defer cancel()
+		models, err := list(ctx)
+		return modelsMsg{provider: provider, models: models, err: err}
+	}
+}
+
+func (m model) applyModels(msg modelsMsg) (model, tea.Cmd) {
+	if msg.provider != m.meta.Provider || m.mode != modeChat {
+		return m, nil
+	}
+	if msg.err != nil {
+		return m, m.showToast("Cannot list models: " + msg.err.Error())
+	}
+	return m.openModels(msg.models)
+}
+
+func (m model) openModels(models []string) (model, tea.Cmd) {
+	if len(models) == 0 {
+		return m, m.showToast("No models known for " + m.meta.Provider + "; /model <name> sets one")
+	}
+
+	items := make([]menuItem, 0, len(models))
+	for i, name := range models {
+		item := menuItem{title: name, id: uint32(i)}
+		if name == m.meta.Model {
+			item.detail = "current"
+		}
+		items = append(items, item)
+	}
+	m.models = models
+	m.menu = menu{title: "Models of " + m.meta.Provider, hint: "enter switch · esc close", items: items}
+	m.mode = modeModels
+	m.textarea.Blur()
+	return m, nil
+}
+
+func (m model) updateModels(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		return m.closeOverlay(), nil
+	case "up", "k":
+		m.menu.up()
+	case "down", "j":
+		m.menu.down()
+	case "enter":
+		item, ok := m.menu.selected()
+		m = m.closeOverlay()
+		if ok {
+			m.meta.Model = m.models[item.id]
+			m.changed()
+			return m, m.showToast("Model: " + backendName(m.meta.Provider, m.meta.Model))
+		}
+	}
+	return m, nil
+}
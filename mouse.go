@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The wheel scrolls whatever is on screen; a left click on a message
+// selects it, on the input box focuses it and on the sidebar focuses that.
+
+func (m model) updateMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		m.scrollWheel(-1)
+		return m, nil
+	case tea.MouseButtonWheelDown:
+		m.scrollWheel(1)
+		return m, nil
+	case tea.MouseButtonLeft:
+		if msg.Action != tea.MouseActionPress {
+			return m, nil
+		}
+	default:
+		return m, nil
+	}
+
+	// only the chat views map clicks; overlays and prompts keep the keyboard
+	if m.mode != modeChat && m.mode != modeNormal && m.mode != modeSelect {
+		return m, nil
+	}
+
+	left := appStyle.GetMarginLeft()
+	top := appStyle.GetMarginTop()
+	if m.sidebar.visible {
+		if msg.X < left+SIDEBAR_WIDTH {
+			m.sidebar.focused = true
+			m.textarea.Blur()
+			return m, nil
+		}
+		left += SIDEBAR_WIDTH
+	}
+
+	contentTop := top + viewportStyle.GetBorderTopSize() + viewportStyle.GetPaddingTop()
+	inputTop := top + m.viewport.Height + viewportStyle.GetVerticalFrameSize() + STATUS_HEIGHT
+	switch {
+	case msg.Y >= inputTop:
+		if m.mode == modeSelect {
+			m = m.closeSelect()
+		}
+		m = m.closeOverlay()
+		m.sidebar.focused = false
+	case msg.Y >= contentTop && msg.Y < contentTop+m.viewport.Height && msg.X >= left:
+		i := m.messageAt(m.viewport.YOffset + msg.Y - contentTop)
+		if i < 0 || m.messages[i].Collapsed {
+			return m, nil
+		}
+		m.sidebar.focused = false
+		if m.mode != modeSelect {
+			var cmd tea.Cmd
+			m, cmd = m.openSelect()
+			if m.mode != modeSelect {
+				return m, cmd
+			}
+		}
+		m.selected = i
+		m.showSelected()
+	}
+	return m, nil
+}
+
+// scrollWheel moves the list under an overlay, or the transcript.
+func (m *model) scrollWheel(step int) {
+	switch {
+	case m.mode == modeSessions || m.mode == modeCheckpoints || m.mode == modeJump || m.mode == modeTemplates:
+		if step < 0 {
+			m.menu.up()
+		} else {
+			m.menu.down()
+		}
+	case m.sidebar.focused:
+		if step < 0 {
+			m.sidebar.menu.up()
+		} else {
+			m.sidebar.menu.down()
+		}
+	case step < 0:
+		m.viewport.ScrollUp(m.viewport.MouseWheelDelta)
+	default:
+		m.viewport.ScrollDown(m.viewport.MouseWheelDelta)
+	}
+}
+
+// messageAt is the message drawn on the given transcript line, or -1 below
+// the last one.
+func (m model) messageAt(line int) int {
+	i := sort.Search(len(m.messages), func(i int) bool {
+		return m.messageOffset(i+1) > line
+	})
+	if i == len(m.messages) {
+		return -1
+	}
+	return i
+}
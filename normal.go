@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Normal mode (esc or tab from the input) drives the transcript from the keyboard
+// vim-style until i hands the keys back to the textarea.
+
+func (m model) openNormal() (model, tea.Cmd) {
+	m.mode = modeNormal
+	m.pendingG = false
+	m.textarea.Blur()
+	return m, nil
+}
+
+func (m model) updateNormal(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	keys := m.keys.Normal
+	if m.pendingG {
+		m.pendingG = false
+		if key.Matches(msg, keys.Top) {
+			m.viewport.GotoTop()
+			return m, nil
+		}
+	}
+
+	switch {
+	case key.Matches(msg, keys.Quit):
+		return m.confirmQuit()
+	case key.Matches(msg, keys.Insert):
+		return m.closeOverlay(), nil
+	case key.Matches(msg, keys.Focus):
+		m = m.closeOverlay()
+		if m.sidebar.visible {
+			m.sidebar.focused = true
+			m.textarea.Blur()
+		}
+		return m, nil
+	case key.Matches(msg, keys.Down):
+		m.viewport.ScrollDown(1)
+	case key.Matches(msg, keys.Up):
+		m.viewport.ScrollUp(1)
+	case key.Matches(msg, keys.HalfDown):
+		m.viewport.HalfPageDown()
+	case key.Matches(msg, keys.HalfUp):
+		m.viewport.HalfPageUp()
+	case key.Matches(msg, keys.PageDown):
+		m.viewport.PageDown()
+	case key.Matches(msg, keys.PageUp):
+		m.viewport.PageUp()
+	case key.Matches(msg, keys.Top):
+		if msg.String() != "g" {
+			m.viewport.GotoTop()
+			return m, nil
+		}
+		m.pendingG = true
+	case key.Matches(msg, keys.Bottom):
+		m.viewport.GotoBottom()
+	case key.Matches(msg, keys.Find):
+		return m.openFind()
+	case key.Matches(msg, keys.Select):
+		return m.openSelect()
+	case key.Matches(msg, keys.Help):
+		return m.openHelp()
+	}
+	return m, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Ollama serves local models; OLLAMA_HOST says where, as it does for the
+// ollama CLI.
+
+const OLLAMA_HOST = "http://localhost:11434"
+
+func ollamaURL(path string) string {
+	host := cmp.Or(os.Getenv("OLLAMA_HOST"), OLLAMA_HOST)
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return strings.TrimSuffix(host, "/") + path
+}
+
+func ollamaChat(ctx context.Context, req apiRequest, chunks chan<- string) (apiReply, error) {
+	if req.model == "" {
+		return apiReply{}, errors.New("no model set; /models lists the installed ones")
+	}
+
+	messages := req.messages
+	if req.system != "" {
+		messages = append([]apiMessage{{Role: "system", Content: req.system}}, messages...)
+	}
+	body := map[string]any{
+		"model":    req.model,
+		"messages": messages,
+		"stream":   true,
+	}
+	options := map[string]any{}
+	if p := req.params; p.Temperature != nil {
+		options["temperature"] = *p.Temperature
+	}
+	if p := req.params; p.TopP != nil {
+		options["top_p"] = *p.TopP
+	}
+	if req.params.MaxTokens > 0 {
+		options["num_predict"] = req.params.MaxTokens
+	}
+	if len(options) > 0 {
+		body["options"] = options
+	}
+	resp, err := postJSON(ctx, ollamaURL("/api/chat"), http.Header{}, body)
+	if err != nil {
+		return apiReply{}, err
+	}
+	defer resp.Body.Close()
+
+	// one JSON object per line rather than server-sent events
+	var out strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var chunk struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			Done            bool   `json:"done"`
+			Error           string `json:"error"`
+			PromptEvalCount int    `json:"prompt_eval_count"`
+			EvalCount       int    `json:"eval_count"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			return apiReply{text: out.String()}, err
+		}
+		if chunk.Error != "" {
+			return apiReply{text: out.String()}, errors.New(chunk.Error)
+		}
+		if text := chunk.Message.Content; text != "" {
+			out.WriteString(text)
+			if chunks != nil {
+				chunks <- text
+			}
+		}
+		if chunk.Done {
+			return apiReply{text: out.String(), usage: Usage{Prompt: chunk.PromptEvalCount, Completion: chunk.EvalCount}}, nil
+		}
+	}
+	return apiReply{text: out.String()}, scanner.Err()
+}
+
+// ollamaModels lists the models pulled into the local server.
+func ollamaModels(ctx context.Context) ([]string, error) {
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := getJSON(ctx, ollamaURL("/api/tags"), nil, &tags); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, model := range tags.Models {
+		names = append(names, model.Name)
+	}
+	return names, nil
+}
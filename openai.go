@@ -0,0 +1,118 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// OpenAI's chat completions API. OPENAI_BASE_URL points it at another
+// server that speaks the same API.
+
+const (
+	OPENAI_BASE_URL = "https://api.openai.com/v1"
+	OPENAI_MODEL    = "gpt-4o-mini" // when the chat sets none
+)
+
+// openaiRequest is where path is on the server and the header that
+// authorizes the key.
+func openaiRequest(path string) (string, http.Header, error) {
+	key := os.Getenv("OPENAI_API_KEY")
+	if key == "" {
+		return "", nil, errors.New("OPENAI_API_KEY is not set")
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+key)
+	return strings.TrimSuffix(cmp.Or(os.Getenv("OPENAI_BASE_URL"), OPENAI_BASE_URL), "/") + path, header, nil
+}
+
+func openaiChat(ctx context.Context, req apiRequest, chunks chan<- string) (apiReply, error) {
+	url, header, err := openaiRequest("/chat/completions")
+	if err != nil {
+		return apiReply{}, err
+	}
+
+	messages := req.messages
+	if req.system != "" {
+		messages = append([]apiMessage{{Role: "system", Content: req.system}}, messages...)
+	}
+	body := map[string]any{
+		"model":    cmp.Or(req.model, OPENAI_MODEL),
+		"messages": messages,
+		"stream":   true,
+		// the last chunk then says how many tokens it took
+		"stream_options": map[string]any{"include_usage": true},
+	}
+	if p := req.params; p.Temperature != nil {
+		body["temperature"] = *p.Temperature
+	}
+	if p := req.params; p.TopP != nil {
+		body["top_p"] = *p.TopP
+	}
+	if req.params.MaxTokens > 0 {
+		body["max_completion_tokens"] = req.params.MaxTokens
+	}
+	resp, err := postJSON(ctx, url, header, body)
+	if err != nil {
+		return apiReply{}, err
+	}
+	defer resp.Body.Close()
+
+	var out strings.Builder
+	var usage Usage
+	err = readEvents(resp.Body, func(data string) (bool, error) {
+		if data == "[DONE]" {
+			return true, nil
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Usage *struct {
+				PromptTokens     int `json:"prompt_tokens"`
+				CompletionTokens int `json:"completion_tokens"`
+			} `json:"usage"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, err
+		}
+		if chunk.Error != nil {
+			return false, errors.New(chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			usage = Usage{Prompt: chunk.Usage.PromptTokens, Completion: chunk.Usage.CompletionTokens}
+		}
+		for _, choice := range chunk.Choices {
+			if text := choice.Delta.Content; text != "" {
+				out.WriteString(text)
+				if chunks != nil {
+					chunks <- text
+				}
+			}
+		}
+		return false, nil
+	})
+	return apiReply{text: out.String(), usage: usage}, err
+}
+
+// openaiModels lists the models the key can use.
+func openaiModels(ctx context.Context) ([]string, error) {
+	url, header, err := openaiRequest("/models")
+	if err != nil {
+		return nil, err
+	}
+	var list modelList
+	if err := getJSON(ctx, url, header, &list); err != nil {
+		return nil, err
+	}
+	return list.names(), nil
+}
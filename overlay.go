@@ -0,0 +1,262 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// mode decides who gets key presses: the chat (textarea + viewport) or
+// one of the overlays drawn on top of it.
+type mode int
+
+const (
+	modeChat mode = iota
+	modePrompt
+	modeCheckpoints
+	modeSessions
+	modeSearch
+	modeSelect
+	modeJump
+	modeTemplates
+	modeStats
+	modeFind
+	modeNormal
+	modeHelp
+	modeQuit
+	modeNotices
+	modeSnippets
+	modeCompare
+	modePalette
+	modeBookmarks
+	modeModels
+)
+
+// covers reports whether the mode draws over the transcript rather than
+// next to it.
+func (md mode) covers() bool {
+	return md != modeChat && md != modePrompt && md != modeSelect && md != modeFind && md != modeNormal && md != modeQuit
+}
+
+var (
+	menuTitleStyle    = lipgloss.NewStyle().Bold(true)
+	menuSelectedStyle = lipgloss.NewStyle()
+	menuDetailStyle   = lipgloss.NewStyle()
+)
+
+type menuItem struct {
+	title  string
+	detail string
+	id     uint32
+}
+
+// menu is a plain selectable list used by the overlays.
+type menu struct {
+	title  string
+	hint   string // keys, shown under the title
+	items  []menuItem
+	cursor int
+}
+
+func (mn *menu) up() {
+	if mn.cursor > 0 {
+		mn.cursor--
+	}
+}
+
+func (mn *menu) down() {
+	if mn.cursor < len(mn.items)-1 {
+		mn.cursor++
+	}
+}
+
+func (mn menu) selected() (menuItem, bool) {
+	if mn.cursor < 0 || mn.cursor >= len(mn.items) {
+		return menuItem{}, false
+	}
+	return mn.items[mn.cursor], true
+}
+
+// find returns the index of the item with the given id, or 0.
+func (mn menu) find(id uint32) int {
+	for i, item := range mn.items {
+		if item.id == id {
+			return i
+		}
+	}
+	return 0
+}
+
+// view cuts rows to width so long (or wide, CJK) titles do not wrap and
+// push the list out of its box.
+func (mn menu) view(width, height int) string {
+	var b strings.Builder
+	b.WriteString(menuTitleStyle.Render(mn.title) + "\n")
+	if mn.hint != "" {
+		hint := ansi.Wrap(mn.hint, width, "")
+		b.WriteString(menuDetailStyle.Render(hint) + "\n")
+		height -= strings.Count(hint, "\n") + 1
+	}
+	b.WriteString("\n")
+
+	if len(mn.items) == 0 {
+		b.WriteString(menuDetailStyle.Render("(empty)"))
+		return b.String()
+	}
+
+	// keep the cursor on screen when the list is taller than the box
+	visible := max(height-2, 1)
+	start := 0
+	if mn.cursor >= visible {
+		start = mn.cursor - visible + 1
+	}
+	end := min(start+visible, len(mn.items))
+
+	for i := start; i < end; i++ {
+		item := mn.items[i]
+		line := "  " + item.title
+		if i == mn.cursor {
+			line = menuSelectedStyle.Render("> " + item.title)
+		}
+		if item.detail != "" {
+			line += "  " + menuDetailStyle.Render(item.detail)
+		}
+		b.WriteString(ansi.Truncate(line, width, "…") + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+type promptAction int
+
+const (
+	promptCheckpoint promptAction = iota + 1
+	promptRename
+)
+
+// prompt asks for a single line of text and hands it to action on enter.
+type prompt struct {
+	action promptAction
+	input  textinput.Model
+}
+
+func newPrompt(action promptAction, label, value string) prompt {
+	ti := textinput.New()
+	ti.Prompt = label + " "
+	ti.SetValue(value)
+	ti.Focus()
+	return prompt{action: action, input: ti}
+}
+
+func (m model) openPrompt(action promptAction, label, value string) (model, tea.Cmd) {
+	m.prompt = newPrompt(action, label, value)
+	m.mode = modePrompt
+	m.textarea.Blur()
+	return m, textinput.Blink
+}
+
+func (m model) closeOverlay() model {
+	m.mode = modeChat
+	m.textarea.Focus()
+	return m
+}
+
+func (m model) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		return m.closeOverlay(), nil
+	case tea.KeyEnter:
+		value := strings.TrimSpace(m.prompt.input.Value())
+		m = m.closeOverlay()
+		if value == "" {
+			return m, nil
+		}
+		switch m.prompt.action {
+		case promptCheckpoint:
+			m.saveCheckpoint(value)
+		case promptRename:
+			m.rename(value)
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.prompt.input, cmd = m.prompt.input.Update(msg)
+	return m, cmd
+}
+
+func (m model) updateOverlay(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch m.mode {
+	case modePrompt:
+		return m.updatePrompt(msg)
+	case modeCheckpoints:
+		return m.updateCheckpoints(msg)
+	case modeSessions:
+		return m.updateSessions(msg)
+	case modeSearch:
+		return m.updateSearch(msg)
+	case modeSelect:
+		return m.updateSelect(msg)
+	case modeJump:
+		return m.updateJump(msg)
+	case modeTemplates:
+		return m.updateTemplates(msg)
+	case modeFind:
+		return m.updateFind(msg)
+	case modeNormal:
+		return m.updateNormal(msg)
+	case modeHelp:
+		return m.updateHelp(msg)
+	case modeQuit:
+		return m.updateQuit(msg)
+	case modeNotices:
+		return m.updateNotices(msg)
+	case modeSnippets:
+		return m.updateSnippets(msg)
+	case modeCompare:
+		return m.updateCompare(msg)
+	case modePalette:
+		return m.updatePalette(msg)
+	case modeBookmarks:
+		return m.updateBookmarks(msg)
+	case modeModels:
+		return m.updateModels(msg)
+	case modeStats:
+		if msg.String() == "esc" || msg.String() == "alt+i" {
+			return m.closeOverlay(), nil
+		}
+	}
+	return m, nil
+}
+
+// overlayView renders the active list overlay in place of the transcript.
+func (m model) overlayView() string {
+	var content string
+	switch m.mode {
+	case modeSessions:
+		content = m.menu.view(m.viewport.Width, m.viewport.Height)
+		if m.filtering || m.filter.Value() != "" {
+			content = m.filter.View() + "\n" + m.menu.view(m.viewport.Width, m.viewport.Height-1)
+		}
+	case modeCheckpoints, modeJump, modeTemplates, modeNotices, modeSnippets, modeBookmarks, modeModels:
+		content = m.menu.view(m.viewport.Width, m.viewport.Height)
+	case modeSearch:
+		content = m.searchView(m.viewport.Width, m.viewport.Height)
+	case modeStats:
+		content = m.statsView()
+	case modeHelp:
+		content = m.helpView(m.viewport.Width, m.viewport.Height)
+	case modeCompare:
+		content = m.compareView(m.viewport.Width, m.viewport.Height)
+	case modePalette:
+		content = m.paletteView(m.viewport.Width, m.viewport.Height)
+	}
+
+	box := lipgloss.NewStyle().
+		Width(m.viewport.Width).
+		Height(m.viewport.Height).
+		Render(content)
+	return viewportStyle.Render(box)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// PALETTE_MAX is how many matches the palette lists.
+const PALETTE_MAX = 100
+
+// paletteEntry is a session or one of its messages in the ctrl+k palette.
+type paletteEntry struct {
+	session uint32 // 0 for the current chat before it is saved
+	index   int    // message within the session; -1 for the session itself
+	title   string // what the query is matched against
+	detail  string
+}
+
+// palette finds sessions and messages by fuzzy matching as you type.
+// Enter opens the session and scrolls to the message.
+type palette struct {
+	input   textinput.Model
+	entries []paletteEntry
+	hits    []paletteEntry
+}
+
+func (m model) openPalette() (model, tea.Cmd) {
+	sessions, err := listSessions(m.storage, false)
+	if err != nil {
+		return m, m.showToast("Cannot list sessions: " + err.Error())
+	}
+
+	var entries []paletteEntry
+	add := func(id uint32, name string, messages []Message) {
+		entries = append(entries, paletteEntry{session: id, index: -1, title: name, detail: fmt.Sprintf("%d messages", len(messages))})
+		for i, message := range messages {
+			if message.Collapsed || strings.TrimSpace(message.Text) == "" {
+				continue
+			}
+			text := strings.Join(strings.Fields(message.Text), " ")
+			entries = append(entries, paletteEntry{session: id, index: i, title: message.Role.Label() + ": " + text, detail: name})
+		}
+	}
+	// the chat on screen may be ahead of what is stored
+	if len(m.messages) > 0 {
+		add(m.currentId, m.sessionTitle(), m.messages)
+	}
+	for _, session := range sessions {
+		if session.Id != m.currentId {
+			add(session.Id, session.Name(), session.Messages)
+		}
+	}
+
+	ti := textinput.New()
+	ti.Prompt = "> "
+	ti.Focus()
+	m.palette = palette{input: ti, entries: entries}
+	m.menu = menu{title: "Go to", hint: "type to filter · ↑/↓ move · enter open · esc close"}
+	m.filterPalette()
+	m.mode = modePalette
+	m.textarea.Blur()
+	return m, textinput.Blink
+}
+
+// filterPalette ranks the entries against the query, best first. With no
+// query only the sessions are listed.
+func (m *model) filterPalette() {
+	query := strings.TrimSpace(m.palette.input.Value())
+	type scored struct {
+		entry paletteEntry
+		score int
+	}
+	var matches []scored
+	for _, entry := range m.palette.entries {
+		if query == "" {
+			if entry.index < 0 {
+				matches = append(matches, scored{entry: entry})
+			}
+			continue
+		}
+		if score, ok := fuzzyScore(query, entry.title); ok {
+			matches = append(matches, scored{entry: entry, score: score})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b scored) int { return b.score - a.score })
+
+	m.palette.hits, m.menu.items = nil, nil
+	for _, match := range matches[:min(len(matches), PALETTE_MAX)] {
+		m.palette.hits = append(m.palette.hits, match.entry)
+		m.menu.items = append(m.menu.items, menuItem{title: match.entry.title, detail: match.entry.detail})
+	}
+	m.menu.cursor = 0
+}
+
+// fuzzyScore reports whether the letters of pattern appear in text in
+// order, and how well: runs of letters and letters at the start of words
+// count more, gaps count against.
+func fuzzyScore(pattern, text string) (int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	t := []rune(strings.ToLower(text))
+	score, run, last := 0, 0, -1
+	for i, j := 0, 0; i < len(p); i++ {
+		if unicode.IsSpace(p[i]) {
+			continue
+		}
+		for j < len(t) && t[j] != p[i] {
+			j++
+		}
+		if j == len(t) {
+			return 0, false
+		}
+		switch {
+		case j == last+1:
+			run++
+			score += 2 * run
+		case j == 0 || !unicode.IsLetter(t[j-1]) && !unicode.IsDigit(t[j-1]):
+			run = 0
+			score += 3
+		default:
+			run = 0
+			score -= min(j-last-1, 3)
+		}
+		score++
+		last = j
+		j++
+	}
+	return score, true
+}
+
+func (m model) updatePalette(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "ctrl+k":
+		return m.closeOverlay(), nil
+	case "up", "ctrl+p":
+		m.menu.up()
+		return m, nil
+	case "down", "ctrl+n":
+		m.menu.down()
+		return m, nil
+	case "enter":
+		if m.menu.cursor >= len(m.palette.hits) {
+			return m, nil
+		}
+		return m.closeOverlay().goTo(m.palette.hits[m.menu.cursor])
+	}
+
+	var cmd tea.Cmd
+	value := m.palette.input.Value()
+	m.palette.input, cmd = m.palette.input.Update(msg)
+	if m.palette.input.Value() != value {
+		m.filterPalette()
+	}
+	return m, cmd
+}
+
+// goTo opens the entry's session, unless it is the one on screen, and
+// scrolls to its message.
+func (m model) goTo(entry paletteEntry) (model, tea.Cmd) {
+	if entry.session != m.currentId {
+		if err := m.loadSession(entry.session); err != nil {
+			m.addMessage(RoleSystem, "Error loading session: "+err.Error())
+			return m, nil
+		}
+	}
+	if entry.index >= 0 && entry.index < len(m.messages) {
+		m.viewport.SetYOffset(m.messageOffset(entry.index))
+	}
+	return m, nil
+}
+
+func (m model) paletteView(width, height int) string {
+	return m.palette.input.View() + "\n\n" + m.menu.view(width, height-2)
+}
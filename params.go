@@ -0,0 +1,121 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Params tune how the backend generates. Unset ones are left to it. The
+// API providers take them all; the claude and gemini CLIs have no flags
+// for them, but a command in chat.commands can pass them on with
+// {temperature}, {top_p} and {max_tokens}.
+type Params struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+}
+
+// paramNames are the names /params takes, in the order it lists them.
+var paramNames = []string{"temperature", "top_p", "max_tokens"}
+
+// over fills in what p leaves unset from base.
+func (p Params) over(base Params) Params {
+	if p.Temperature == nil {
+		p.Temperature = base.Temperature
+	}
+	if p.TopP == nil {
+		p.TopP = base.TopP
+	}
+	if p.MaxTokens == 0 {
+		p.MaxTokens = base.MaxTokens
+	}
+	return p
+}
+
+// get is the value of the named parameter as text, empty when unset.
+func (p Params) get(name string) string {
+	switch name {
+	case "temperature":
+		if p.Temperature != nil {
+			return strconv.FormatFloat(*p.Temperature, 'g', -1, 64)
+		}
+	case "top_p":
+		if p.TopP != nil {
+			return strconv.FormatFloat(*p.TopP, 'g', -1, 64)
+		}
+	case "max_tokens":
+		if p.MaxTokens > 0 {
+			return strconv.Itoa(p.MaxTokens)
+		}
+	}
+	return ""
+}
+
+// set changes the named parameter; "default" unsets it.
+func (p *Params) set(name, value string) error {
+	if value == "default" {
+		switch name {
+		case "temperature":
+			p.Temperature = nil
+		case "top_p":
+			p.TopP = nil
+		case "max_tokens":
+			p.MaxTokens = 0
+		default:
+			return fmt.Errorf("unknown parameter %s", name)
+		}
+		return nil
+	}
+
+	switch name {
+	case "temperature", "top_p":
+		limit := 2.0
+		if name == "top_p" {
+			limit = 1
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v < 0 || v > limit {
+			return fmt.Errorf("%s is a number from 0 to %g", name, limit)
+		}
+		if name == "temperature" {
+			p.Temperature = &v
+		} else {
+			p.TopP = &v
+		}
+	case "max_tokens":
+		v, err := strconv.Atoi(value)
+		if err != nil || v <= 0 {
+			return fmt.Errorf("max_tokens is a whole number above 0")
+		}
+		p.MaxTokens = v
+	default:
+		return fmt.Errorf("unknown parameter %s; there are %s", name, strings.Join(paramNames, ", "))
+	}
+	return nil
+}
+
+func (p Params) String() string {
+	parts := make([]string, 0, len(paramNames))
+	for _, name := range paramNames {
+		parts = append(parts, name+" "+cmp.Or(p.get(name), "default"))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// params shows the chat's parameters or sets one of them for this chat.
+func (m *model) params(args string) string {
+	name, value, _ := strings.Cut(args, " ")
+	if name == "" {
+		return "Params: " + m.meta.Params.over(m.chat.Params).String()
+	}
+	if value = strings.TrimSpace(value); value == "" {
+		return "Usage: /params " + name + " <value|default>"
+	}
+	if err := m.meta.Params.set(name, value); err != nil {
+		return "Cannot set params: " + err.Error()
+	}
+	m.changed()
+	return "Params: " + m.meta.Params.over(m.chat.Params).String()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// PASTE_BURST is how close together keys have to arrive to be taken as a
+// paste rather than typing. Terminals without bracketed paste send a pasted
+// block as ordinary keys, a newline as enter.
+const PASTE_BURST = 10 * time.Millisecond
+
+// paste inserts a bracketed paste into the input as it is, newlines and all.
+func (m *model) paste(msg tea.KeyMsg) {
+	text := strings.ReplaceAll(string(msg.Runes), "\r\n", "\n")
+	m.textarea.InsertString(text)
+}
+
+// inBurst records the key and reports whether it came right on the heels of
+// the previous one. Enter in a burst is a line of pasted text, not a send.
+func (m *model) inBurst(msg tea.KeyMsg) bool {
+	now := time.Now()
+	burst := now.Sub(m.typedAt) < PASTE_BURST
+	switch msg.Type {
+	case tea.KeyRunes, tea.KeySpace, tea.KeyTab, tea.KeyEnter:
+		m.typedAt = now
+	default:
+		m.typedAt = time.Time{}
+	}
+	return burst
+}
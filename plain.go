@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Plain mode is for screen readers and terminals that cannot take the
+// full-screen interface: no borders, colors or alternate screen, just the
+// conversation printed line after line and a prompt read from stdin.
+
+// plainCommands are the slash commands that make sense without the
+// interface; the rest open overlays.
+var plainCommands = []string{"new", "open", "save", "clear", "provider", "model", "system", "params", "attach", "tag", "untag", "export"}
+
+func runPlain(cfg Config) {
+	m := initialModel(cfg)
+	go func() {
+		// storage notices are toasts in the interface; here they are dropped
+		for range m.pipe {
+		}
+	}()
+
+	out := os.Stdout
+	if m.currentId != 0 {
+		fmt.Fprintln(out, strings.TrimSpace(fmt.Sprintf("Session #%d %s", m.currentId, m.meta.Title))+"\n")
+	}
+	printPlain(out, m.messages)
+	check := func() {
+		if problem := backendProblem(m.ctx, m.meta.Provider); problem != "" {
+			fmt.Fprintln(out, problem+"\n")
+		}
+	}
+	check()
+	fmt.Fprintln(out, "Type a message and press enter. End a line with \\ to continue it; /quit exits.")
+
+	in := bufio.NewScanner(os.Stdin)
+	var lines []string
+	prompt := func() {
+		if len(lines) > 0 {
+			fmt.Fprint(out, "… ")
+		} else {
+			fmt.Fprint(out, "> ")
+		}
+	}
+	for prompt(); in.Scan(); prompt() {
+		line := in.Text()
+		if more, ok := strings.CutSuffix(line, "\\"); ok {
+			lines = append(lines, more)
+			continue
+		}
+		input := strings.TrimSpace(strings.Join(append(lines, line), "\n"))
+		lines = nil
+		if input == "" {
+			continue
+		}
+		if input == "/quit" || input == "/exit" {
+			break
+		}
+
+		before, toast, provider := len(m.messages), m.toastId, m.meta.Provider
+		if name, ok := strings.CutPrefix(input, "/"); ok {
+			name, _, _ = strings.Cut(name, " ")
+			if !slices.Contains(plainCommands, name) {
+				fmt.Fprintf(out, "/%s is not available in plain mode\n", name)
+				continue
+			}
+			m, _ = m.runSlashCommand(input)
+		} else {
+			m.sendPlain(out, input)
+			continue
+		}
+		if m.toastId != toast && m.toast != "" {
+			fmt.Fprintln(out, m.toast)
+		}
+		if len(m.messages) < before {
+			before = 0
+		}
+		printPlain(out, m.messages[before:])
+		if m.meta.Provider != provider {
+			check()
+		}
+	}
+	if m.autosave && m.dirty() {
+		m.savePlain(out)
+	}
+	m.stopBackends()
+}
+
+// savePlain saves the chat and says so when that fails; the notice save
+// sends is dropped here.
+func (m *model) savePlain(out io.Writer) {
+	if err := m.save(); err != nil {
+		fmt.Fprintln(out, "Could not save the chat: "+err.Error())
+	}
+}
+
+// sendPlain asks the backend and prints the response as it streams in.
+// Ctrl+c cancels it and returns to the prompt.
+func (m *model) sendPlain(out io.Writer, input string) {
+	files := m.attached(input)
+	prompt, err := withAttachments(input, files)
+	if err != nil {
+		fmt.Fprintln(out, "Cannot attach: "+err.Error())
+		return
+	}
+	m.addMessage(RoleUser, input)
+	m.messages[len(m.messages)-1].Attachments = attachmentNames(files)
+	m.files = nil
+
+	// the command has a process group of its own, so the interrupt only
+	// reaches relay, which kills it
+	ctx, stop := signal.NotifyContext(m.ctx, os.Interrupt)
+	defer stop()
+	stream := make(chan string, 64)
+	req := m.chatRequest(prompt)
+	req.notBefore = reserveSlot(req.provider, time.Now())
+	if wait := time.Until(req.notBefore).Round(time.Second); wait > 0 {
+		fmt.Fprintf(out, "Rate limited, sending in %s\n", wait)
+	}
+	run := runChatCommand(ctx, 0, req, stream)
+	var msg cliResponseMsg
+	var streamed string
+	for {
+		done := make(chan tea.Msg, 1)
+		go func() { done <- run() }()
+		fmt.Fprint(out, RoleBot.Label()+": ")
+		streamed = ""
+		for chunk := range stream {
+			fmt.Fprint(out, chunk)
+			streamed += chunk
+		}
+		result := <-done
+		retry, ok := result.(retryMsg)
+		if !ok {
+			msg = result.(cliResponseMsg)
+			break
+		}
+		fmt.Fprint(out, "\n\n")
+		m.addMessage(RoleSystem, retry.note)
+		printPlain(out, m.messages[len(m.messages)-1:])
+		stream, run = retry.stream, retry.next
+	}
+	if ctx.Err() != nil || msg.failed {
+		note := "Cancelled"
+		if ctx.Err() == nil {
+			note = msg.text
+		}
+		fmt.Fprint(out, "\n\n")
+		m.addMessage(RoleSystem, note)
+		printPlain(out, m.messages[len(m.messages)-1:])
+		if m.autosave {
+			m.savePlain(out)
+		}
+		return
+	}
+	if msg.text != streamed {
+		// errors are not streamed
+		fmt.Fprint(out, msg.text)
+	}
+	if !strings.HasSuffix(msg.text, "\n") {
+		fmt.Fprintln(out)
+	}
+	fmt.Fprintln(out)
+
+	m.addMessage(RoleBot, strings.TrimRight(msg.text, "\n"))
+	m.messages[len(m.messages)-1].Latency = msg.latency.Milliseconds()
+	m.messages[len(m.messages)-1].Usage = msg.usage
+	m.meta.Summary, m.meta.Summarized = msg.summary, msg.summarized
+	m.keepResume(msg.session)
+	if m.autosave {
+		m.savePlain(out)
+	}
+}
+
+// printPlain writes messages as "Role: text", one blank line after each.
+func printPlain(out io.Writer, messages []Message) {
+	for _, message := range messages {
+		if message.Collapsed {
+			continue
+		}
+		fmt.Fprintf(out, "%s: %s\n", message.Role.Label(), message.Text)
+		if len(message.Attachments) > 0 {
+			fmt.Fprintf(out, "attached: %s\n", strings.Join(message.Attachments, ", "))
+		}
+		fmt.Fprintln(out)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+)
+
+// Price is what a model charges, in dollars per million tokens.
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// prices are the list prices of the models relay knows, by name prefix so
+// dated snapshots ("claude-sonnet-4-5-20250929") match too; chat.prices
+// adds to and overrides them. Local models cost nothing and have none.
+var prices = map[string]Price{
+	"gpt-4o":            {2.50, 10},
+	"gpt-4o-mini":       {0.15, 0.60},
+	"gpt-4.1":           {2, 8},
+	"gpt-4.1-mini":      {0.40, 1.60},
+	"gpt-4.1-nano":      {0.10, 0.40},
+	"gpt-5":             {1.25, 10},
+	"gpt-5-mini":        {0.25, 2},
+	"gpt-5-nano":        {0.05, 0.40},
+	"o3":                {2, 8},
+	"o3-mini":           {1.10, 4.40},
+	"o4-mini":           {1.10, 4.40},
+	"claude-opus-4":     {15, 75},
+	"claude-sonnet-4":   {3, 15},
+	"claude-3-7-sonnet": {3, 15},
+	"claude-haiku-4-5":  {1, 5},
+	"claude-3-5-haiku":  {0.80, 4},
+	"gemini-2.5-pro":    {1.25, 10},
+	"gemini-2.5-flash":  {0.30, 2.50},
+}
+
+func setPrices(chat ChatConfig) {
+	for model, price := range chat.Prices {
+		prices[model] = price
+	}
+}
+
+// priceFor finds the price of the model by the longest prefix of its name
+// that has one.
+func priceFor(model string) (Price, bool) {
+	if full, ok := anthropicAliases[model]; ok {
+		model = full
+	}
+	var price Price
+	found := ""
+	for prefix, p := range prices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(found) {
+			price, found = p, prefix
+		}
+	}
+	return price, found != ""
+}
+
+// exchangeCost is what the exchange cost in dollars at the model's price,
+// or 0 when relay does not know it.
+func exchangeCost(provider, model string, usage Usage) float64 {
+	price, ok := priceFor(cmp.Or(model, defaultModels[provider]))
+	if !ok {
+		return 0
+	}
+	return (float64(usage.Prompt)*price.Input + float64(usage.Completion)*price.Output) / 1e6
+}
+
+// formatCost reads like "$0.0042" or "$1.25".
+func formatCost(cost float64) string {
+	if cost < 1 {
+		return fmt.Sprintf("$%.4f", cost)
+	}
+	return fmt.Sprintf("$%.2f", cost)
+}
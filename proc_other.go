@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// killGroup leaves the command as it is; cancelling kills only the process
+// itself.
+func killGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_PROVIDER = "echo"
+	KILL_GRACE       = time.Second // how long a cancelled command's output may linger
+)
+
+// providers build the command that answers one prompt. The model is passed
+// through when set; otherwise the CLI picks its own default. The command is
+// killed when ctx is cancelled.
+var providers = map[string]func(ctx context.Context, model, input string, params Params) *exec.Cmd{
+	"echo": func(ctx context.Context, model, input string, params Params) *exec.Cmd {
+		return exec.CommandContext(ctx, "echo", "Simulated AI Response to: "+input)
+	},
+	"claude": builtinCommand("claude -p --model {model} {stdin}"),
+	"gemini": builtinCommand("gemini -m {model} {stdin}"),
+}
+
+// builtinCommand is commandProvider for the templates above, which are
+// known to parse.
+func builtinCommand(template string) func(ctx context.Context, model, input string, params Params) *exec.Cmd {
+	command, err := commandProvider(template)
+	if err != nil {
+		panic(err)
+	}
+	return command
+}
+
+// cliAdapter knows more about a CLI than how to run it.
+type cliAdapter struct {
+	filters []string                  // what the output goes through, see filtersFor
+	explain func(stderr string) error // what went wrong, from what the CLI wrote to stderr
+}
+
+// plainExplain is the stderr as it is, for CLIs without an explain.
+func plainExplain(stderr string) error {
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		return errors.New(stderr)
+	}
+	return nil
+}
+
+var adapters = map[string]cliAdapter{
+	"gemini": {explain: explainGemini},
+}
+
+// providerModels are offered when completing /model; any other name the
+// backend knows works too.
+var providerModels = map[string][]string{
+	"claude":    {"opus", "sonnet", "haiku"},
+	CLAUDE_CODE: {"opus", "sonnet", "haiku"},
+	"gemini":    {"gemini-2.5-pro", "gemini-2.5-flash"},
+	"openai":    {"gpt-4o", "gpt-4o-mini", "gpt-4.1", "o4-mini"},
+	"anthropic": {"opus", "sonnet", "haiku", "claude-opus-4-1", "claude-sonnet-4-5", "claude-haiku-4-5"},
+}
+
+// commandProvider builds a provider from a command line template. Words
+// are split like a shell would, quotes and backslashes included, and
+// {prompt} and {model} are filled in afterwards, so the prompt stays one
+// argument whatever it contains. {transcript} is the path of a temporary
+// file holding the prompt, for CLIs that read the conversation from a
+// file. {temperature}, {top_p} and {max_tokens} are the chat's Params.
+// A word left empty (no model set) is dropped, along with the flag
+// right before it. {stdin} on its own writes the prompt to the command's
+// stdin instead, keeping it out of argv and process listings; that is
+// also what happens without {prompt} or {transcript}.
+func commandProvider(template string) (func(ctx context.Context, model, input string, params Params) *exec.Cmd, error) {
+	words, err := splitCommand(template)
+	if err != nil {
+		return nil, err
+	}
+	stdin := slices.Contains(words, "{stdin}") || !strings.Contains(template, "{prompt}") && !strings.Contains(template, "{transcript}")
+	words = slices.DeleteFunc(words, func(word string) bool { return word == "{stdin}" })
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	return func(ctx context.Context, model, input string, params Params) *exec.Cmd {
+		transcript := ""
+		if strings.Contains(template, "{transcript}") {
+			transcript = writeTranscriptFile(ctx, input)
+		}
+		fill := strings.NewReplacer("{prompt}", input, "{model}", model, "{transcript}", transcript,
+			"{temperature}", params.get("temperature"), "{top_p}", params.get("top_p"), "{max_tokens}", params.get("max_tokens"))
+		args := make([]string, 0, len(words))
+		for _, word := range words {
+			arg := fill.Replace(word)
+			if arg == "" && word != "" {
+				if n := len(args); n > 1 && strings.HasPrefix(args[n-1], "-") {
+					args = args[:n-1]
+				}
+				continue
+			}
+			args = append(args, arg)
+		}
+		if len(args) == 0 {
+			// starting it fails with Err
+			return &exec.Cmd{Err: fmt.Errorf("%q leaves no command to run", template)}
+		}
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		if stdin {
+			cmd.Stdin = strings.NewReader(input)
+		}
+		return cmd
+	}, nil
+}
+
+// writeTranscriptFile puts the prompt in a temporary file that is removed
+// once ctx is done, which streamBackend sees to when the command exits.
+// The path is empty if the file cannot be written.
+func writeTranscriptFile(ctx context.Context, prompt string) string {
+	f, err := os.CreateTemp("", "relay-transcript-*.txt")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	context.AfterFunc(ctx, func() { os.Remove(f.Name()) })
+	if _, err := f.WriteString(prompt); err != nil {
+		return ""
+	}
+	return f.Name()
+}
+
+// splitCommand splits a command line into words the way a POSIX shell
+// does, without expanding anything.
+func splitCommand(line string) ([]string, error) {
+	var (
+		words  []string
+		word   strings.Builder
+		inWord bool
+		quote  rune
+		escape bool
+	)
+	for _, r := range line {
+		switch {
+		case escape:
+			word.WriteRune(r)
+			escape = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escape, inWord = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escape {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", line)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// timeouts bound how long each provider's command may run; see
+// setTimeouts.
+var (
+	defaultTimeout time.Duration
+	timeouts       = map[string]time.Duration{}
+)
+
+func setTimeouts(chat ChatConfig) {
+	defaultTimeout = time.Duration(chat.TimeoutSeconds) * time.Second
+	for provider, seconds := range chat.Timeouts {
+		timeouts[provider] = time.Duration(seconds) * time.Second
+	}
+}
+
+func timeoutFor(provider string) time.Duration {
+	if timeout, ok := timeouts[provider]; ok {
+		return timeout
+	}
+	return defaultTimeout
+}
+
+// registerCommands adds the providers configured in chat.commands.
+func registerCommands(commands map[string]string) error {
+	var errs []error
+	for name, template := range commands {
+		command, err := commandProvider(template)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("provider %s: %w", name, err))
+			continue
+		}
+		providers[name] = command
+		delete(apiProviders, name)
+		delete(setupHints, name)
+	}
+	return errors.Join(errs...)
+}
+
+func providerNames() []string {
+	names := slices.AppendSeq(slices.Collect(maps.Keys(providers)), maps.Keys(apiProviders))
+	slices.Sort(names)
+	return names
+}
+
+func knownProvider(name string) bool {
+	_, ok := providers[name]
+	return ok || isAPI(name)
+}
+
+// backendName is how a session's provider and model are shown.
+// An unset model shows as the one the provider falls back to, when relay
+// knows it.
+func backendName(provider, model string) string {
+	model = cmp.Or(model, defaultModels[provider])
+	if model == "" {
+		return provider
+	}
+	return provider + "/" + model
+}
+
+// defaultModels are what the API providers use when the chat sets no
+// model; the CLIs pick their own.
+var defaultModels = map[string]string{
+	"openai":    OPENAI_MODEL,
+	"anthropic": ANTHROPIC_MODEL,
+}
+
+// running counts the backend commands that have not exited yet, so
+// relay can wait for the cancelled ones to be killed before it exits.
+var running sync.WaitGroup
+
+// askBackend asks on relay's own behalf (titles, summaries), within the
+// provider's rate limit like any other request.
+func askBackend(ctx context.Context, provider, model, input string) (string, error) {
+	select {
+	case <-time.After(time.Until(reserveSlot(provider, time.Now()))):
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	return streamBackend(ctx, provider, model, input, Params{}, nil)
+}
+
+// streamBackend runs the provider's command and sends its stdout on
+// chunks as it comes, if chunks is not nil. It returns everything that
+// was written. Stderr is kept for the error when the command fails, and
+// stands in for the answer when nothing came on stdout.
+func streamBackend(ctx context.Context, provider, model, input string, params Params, chunks chan<- string) (string, error) {
+	if isAPI(provider) {
+		reply, err := streamAPI(ctx, provider, apiRequest{model: model, params: params, messages: []apiMessage{{Role: "user", Content: input}}}, chunks)
+		return reply.text, err
+	}
+	command, ok := providers[provider]
+	if !ok {
+		return "", fmt.Errorf("unknown provider %q", provider)
+	}
+
+	// anything the command set up for itself goes when it is done
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timeout := timeoutFor(provider)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	adapter := adapters[provider]
+	adapter.filters = filtersFor(provider)
+	out, err := runCommand(command(ctx, model, input, params), adapter, chunks)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("%s gave no answer within %s", provider, timeout)
+	}
+	return out, err
+}
+
+// runCommand runs cmd in a process group of its own, see killGroup, and
+// does the streaming for streamBackend.
+func runCommand(cmd *exec.Cmd, adapter cliAdapter, chunks chan<- string) (string, error) {
+	killGroup(cmd)
+	r, w := io.Pipe()
+	var stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = w, &stderr
+	// a killed CLI can leave children holding the output open
+	cmd.WaitDelay = KILL_GRACE
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		err := cmd.Wait()
+		explain := adapter.explain
+		if explain == nil {
+			explain = plainExplain
+		}
+		if reason := explain(stderr.String()); err != nil && reason != nil {
+			err = fmt.Errorf("%w: %w", err, reason)
+		}
+		w.CloseWithError(err)
+	}()
+
+	filtered := newPipeline(adapter.filters)
+	emit := func(text string) {
+		if text != "" && chunks != nil {
+			chunks <- text
+		}
+	}
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			emit(filtered.write(string(buf[:n])))
+		}
+		if err == io.EOF {
+			emit(filtered.flush())
+			if filtered.String() == "" {
+				// Wait has returned, so stderr is complete
+				return filterText(adapter.filters, stderr.String()), nil
+			}
+			return filtered.String(), nil
+		}
+		if err != nil {
+			return filtered.String(), err
+		}
+	}
+}
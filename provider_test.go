@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		line  string
+		words []string
+	}{
+		{"", nil},
+		{"  echo   hi  ", []string{"echo", "hi"}},
+		{"llm -m {model}\t{prompt}", []string{"llm", "-m", "{model}", "{prompt}"}},
+		{`say 'two words' "and \"more\""`, []string{"say", "two words", `and "more"`}},
+		{`a\ b 'c\d'`, []string{"a b", `c\d`}},
+		{`'' ""`, []string{"", ""}},
+		{"x'y'z", []string{"xyz"}},
+	}
+	for _, tt := range tests {
+		words, err := splitCommand(tt.line)
+		if err != nil {
+			t.Errorf("splitCommand(%q): %v", tt.line, err)
+			continue
+		}
+		if !slices.Equal(words, tt.words) {
+			t.Errorf("splitCommand(%q) = %q, want %q", tt.line, words, tt.words)
+		}
+	}
+
+	for _, line := range []string{`say 'open`, `say "open`, `trailing\`} {
+		if _, err := splitCommand(line); err == nil {
+			t.Errorf("splitCommand(%q) did not fail", line)
+		}
+	}
+}
+
+func TestCommandProvider(t *testing.T) {
+	params := Params{}
+	if err := params.set("temperature", "0.5"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		template string
+		model    string
+		args     []string
+		stdin    bool
+	}{
+		{"llm -m {model} {prompt}", "big", []string{"llm", "-m", "big", "-p x"}, false},
+		{"llm -m {model} {prompt}", "", []string{"llm", "-p x"}, false},
+		{"llm --model={model} {prompt}", "", []string{"llm", "--model=", "-p x"}, false},
+		{"llm -t {temperature} --top {top_p} {prompt}", "", []string{"llm", "-t", "0.5", "-p x"}, false},
+		{"'my llm' '[{prompt}]'", "", []string{"my llm", "[-p x]"}, false},
+		{"claude -p --model {model} {stdin}", "", []string{"claude", "-p"}, true},
+		{"llm {model}", "big", []string{"llm", "big"}, true},
+	}
+	for _, tt := range tests {
+		build, err := commandProvider(tt.template)
+		if err != nil {
+			t.Errorf("commandProvider(%q): %v", tt.template, err)
+			continue
+		}
+		cmd := build(context.Background(), tt.model, "-p x", params)
+		if !slices.Equal(cmd.Args, tt.args) {
+			t.Errorf("%q with model %q runs %q, want %q", tt.template, tt.model, cmd.Args, tt.args)
+		}
+		if stdin := cmd.Stdin != nil; stdin != tt.stdin {
+			t.Errorf("%q sends the prompt on stdin: %v, want %v", tt.template, stdin, tt.stdin)
+		} else if stdin {
+			if input, _ := io.ReadAll(cmd.Stdin); string(input) != "-p x" {
+				t.Errorf("%q writes %q to stdin", tt.template, input)
+			}
+		}
+	}
+
+	for _, template := range []string{"", "  ", "{stdin}", "'unterminated"} {
+		if _, err := commandProvider(template); err == nil {
+			t.Errorf("commandProvider(%q) did not fail", template)
+		}
+	}
+	build, err := commandProvider("{model} {prompt}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd := build(context.Background(), "", "", params); cmd.Err == nil {
+		t.Errorf("a template that expands to nothing runs %q", cmd.Args)
+	}
+}
+
+func TestCommandProviderTranscript(t *testing.T) {
+	build, err := commandProvider("cat {transcript}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := build(ctx, "", "hello\nworld", Params{})
+	path := cmd.Args[1]
+	if data, err := os.ReadFile(path); err != nil || string(data) != "hello\nworld" {
+		t.Errorf("transcript file holds %q, %v", data, err)
+	}
+	if !strings.HasPrefix(path, os.TempDir()) {
+		t.Errorf("transcript file %s is not in the temporary directory", path)
+	}
+	cancel()
+	for range 100 {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("transcript file %s is still there after the command", path)
+}
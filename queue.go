@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// enqueue holds a message sent while a response is pending; it goes out
+// when the response arrives.
+func (m *model) enqueue(text string) {
+	m.queue = append(m.queue, text)
+	m.textarea.Reset()
+}
+
+// sendQueued submits the oldest queued message, if any.
+func (m model) sendQueued(cmd tea.Cmd) (model, tea.Cmd) {
+	if len(m.queue) == 0 || m.cliLoading {
+		return m, cmd
+	}
+	next := m.queue[0]
+	m.queue = m.queue[1:]
+	return m.submit(next, cmd)
+}
+
+// unqueue puts the queued messages back in front of whatever is in the
+// input, so nothing typed is lost.
+func (m *model) unqueue() {
+	if len(m.queue) == 0 {
+		return
+	}
+	parts := m.queue
+	if input := strings.TrimSpace(m.textarea.Value()); input != "" {
+		parts = append(parts, input)
+	}
+	m.textarea.SetValue(strings.Join(parts, "\n\n"))
+	m.queue = nil
+}
+
+func (m model) queueStatus() string {
+	if len(m.queue) == 1 {
+		return "1 queued"
+	}
+	return fmt.Sprintf("%d queued", len(m.queue))
+}
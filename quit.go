@@ -0,0 +1,37 @@
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Quitting with changes that are not in storage yet asks first instead of
+// dropping them: y saves and quits, n quits anyway, esc goes back.
+
+func (m model) confirmQuit() (tea.Model, tea.Cmd) {
+	if !m.dirty() {
+		return m, tea.Quit
+	}
+	m.mode = modeQuit
+	m.textarea.Blur()
+	return m, nil
+}
+
+func (m model) updateQuit(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "y", "Y", "enter":
+		if m.save() != nil {
+			// the notice says why
+			return m.closeOverlay(), nil
+		}
+		return m, tea.Quit
+	case "n", "N", "ctrl+c":
+		return m, tea.Quit
+	case "esc", "c":
+		return m.closeOverlay(), nil
+	}
+	return m, nil
+}
+
+func (m model) quitView() string {
+	return menuSelectedStyle.Render("Save before quitting?") + "  " + menuDetailStyle.Render("y save · n discard · esc cancel")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Requests to a provider are spaced so that no more than its limit go out
+// in any minute; one over the limit waits for a slot, counting down in the
+// status bar, rather than being turned away by the provider.
+
+var (
+	defaultRate int
+	rates       = map[string]int{} // requests per minute by provider; 0 is no limit
+
+	slotsMu sync.Mutex
+	slots   = map[string][]time.Time{} // when the requests of the last minute went out or will, oldest first
+)
+
+func setRateLimits(chat ChatConfig) {
+	defaultRate = chat.RequestsPerMinute
+	for provider, rate := range chat.RateLimits {
+		rates[provider] = rate
+	}
+}
+
+func rateFor(provider string) int {
+	if rate, ok := rates[provider]; ok {
+		return rate
+	}
+	return defaultRate
+}
+
+// reserveSlot books the first time from at that a request to provider
+// stays within its limit. Requests go out in the order they were booked.
+func reserveSlot(provider string, at time.Time) time.Time {
+	rate := rateFor(provider)
+	if rate <= 0 {
+		return at
+	}
+	slotsMu.Lock()
+	defer slotsMu.Unlock()
+
+	booked := slots[provider]
+	for len(booked) > 0 && time.Since(booked[0]) >= time.Minute {
+		booked = booked[1:]
+	}
+	if n := len(booked); n > 0 {
+		at = later(at, booked[n-1])
+		if n >= rate {
+			at = later(at, booked[n-rate].Add(time.Minute))
+		}
+	}
+	slots[provider] = append(booked, at)
+	return at
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
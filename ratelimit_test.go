@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReserveSlot(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		rate  int
+		asked []time.Duration // from now, in the order the requests are booked
+		got   []time.Duration
+	}{
+		{"no limit", 0, []time.Duration{0, 0, 0}, []time.Duration{0, 0, 0}},
+		{"under the limit", 3, []time.Duration{0, 0, 0}, []time.Duration{0, 0, 0}},
+		{"over the limit", 2, []time.Duration{0, 0, 0, 0, 0}, []time.Duration{0, 0, time.Minute, time.Minute, 2 * time.Minute}},
+		{"spaced out already", 1, []time.Duration{0, time.Minute, 3 * time.Minute}, []time.Duration{0, time.Minute, 3 * time.Minute}},
+		{"a retry after its wait", 2, []time.Duration{0, 4 * time.Second, 0}, []time.Duration{0, 4 * time.Second, time.Minute}},
+		{"in booking order", 5, []time.Duration{10 * time.Second, 0}, []time.Duration{10 * time.Second, 10 * time.Second}},
+	}
+	for _, tt := range tests {
+		provider := "test " + tt.name
+		rates[provider] = tt.rate
+		for i, asked := range tt.asked {
+			if got := reserveSlot(provider, now.Add(asked)); !got.Equal(now.Add(tt.got[i])) {
+				t.Errorf("%s: request %d asked for +%s, got +%s, want +%s", tt.name, i+1, asked, got.Sub(now), tt.got[i])
+			}
+		}
+		delete(rates, provider)
+		delete(slots, provider)
+	}
+}
+
+func TestReserveSlotForgetsOldRequests(t *testing.T) {
+	provider := "test forgets"
+	rates[provider] = 1
+	defer delete(rates, provider)
+	defer delete(slots, provider)
+
+	// a request that went out over a minute ago no longer counts
+	slots[provider] = []time.Time{time.Now().Add(-2 * time.Minute)}
+	now := time.Now()
+	if got := reserveSlot(provider, now); !got.Equal(now) {
+		t.Errorf("waits %s for a request of two minutes ago", got.Sub(now))
+	}
+	if len(slots[provider]) != 1 {
+		t.Errorf("%d requests remembered, want 1", len(slots[provider]))
+	}
+}
+
+func TestRateFor(t *testing.T) {
+	defer func(rate int) { defaultRate = rate }(defaultRate)
+	defer clear(rates)
+	setRateLimits(ChatConfig{RequestsPerMinute: 30, RateLimits: map[string]int{"ollama": 0, "openai": 10}})
+
+	for provider, want := range map[string]int{"ollama": 0, "openai": 10, "claude": 30} {
+		if rate := rateFor(provider); rate != want {
+			t.Errorf("rateFor(%q) = %d, want %d", provider, rate, want)
+		}
+	}
+}
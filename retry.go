@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// RETRY_BACKOFF is the wait before the first retry; it doubles with each
+// one after.
+const RETRY_BACKOFF = 4 * time.Second
+
+// transientCodes are the statuses of a backend that may well answer when
+// asked again: rate limits and failing or overloaded servers.
+var transientCodes = []int{429, 500, 502, 503, 504, 529}
+
+// transient is true for a statusError with one of transientCodes and for
+// network trouble on the way to an API; what a CLI prints is only known
+// through its adapter, see cliAdapter.
+func transient(err error) bool {
+	var status statusError
+	if errors.As(err, &status) {
+		return slices.Contains(transientCodes, status.code)
+	}
+	var dns *net.DNSError
+	var op *net.OpError
+	var network net.Error
+	return errors.As(err, &dns) || errors.As(err, &op) || errors.As(err, &network) && network.Timeout()
+}
+
+// retryMsg says a request failed and is tried again after a wait. The next
+// attempt streams on a channel of its own, since the last one is closed.
+type retryMsg struct {
+	request int
+	note    string // "rate limit exceeded; retrying in 4s, attempt 2/3"
+	stream  chan string
+	next    tea.Cmd
+}
+
+func (r chatRequest) retry(ctx context.Context, request int, err error) retryMsg {
+	r.notBefore = reserveSlot(r.provider, time.Now().Add(RETRY_BACKOFF<<r.attempt))
+	wait := time.Until(r.notBefore).Round(time.Second)
+	r.attempt++
+	reason := err.Error()
+	if status := (statusError{}); errors.As(err, &status) {
+		// "exit status 1: <stderr>" says nothing the stderr does not
+		reason = status.Error()
+	}
+	reason, _, _ = strings.Cut(strings.TrimSpace(reason), "\n")
+	stream := make(chan string, 64)
+	run := runChatCommand(ctx, request, r, stream)
+	return retryMsg{
+		request: request,
+		note:    fmt.Sprintf("%s; retrying in %s, attempt %d/%d", reason, wait, r.attempt+1, r.retries+1),
+		stream:  stream,
+		next:    run,
+	}
+}
+
+// applyRetry posts the note and follows the next attempt's stream.
+func (m *model) applyRetry(msg retryMsg) tea.Cmd {
+	m.addMessage(RoleSystem, msg.note)
+	m.stream, m.partial = msg.stream, ""
+	m.heardAt = time.Now()
+	return tea.Batch(msg.next, waitForStream(msg.stream))
+}
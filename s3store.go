@@ -0,0 +1,541 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	S3_MANIFEST_NAME = "manifest.json"
+	S3_SESSION_DIR   = "sessions"
+	S3_VERSION_DIR   = "versions"
+	S3_TIMEOUT       = 30 * time.Second
+	S3_FETCHES       = 8 // sessions fetched at once when connecting
+	S3_ATTEMPTS      = 5 // tries at a write another machine keeps beating
+)
+
+// s3Manifest is stored next to the session objects so listing doesn't
+// need a ListObjects call per startup.
+type s3Manifest struct {
+	Count    uint32           `json:"count"`
+	Sessions map[uint32]int64 `json:"sessions"` // id -> UpdatedAt
+}
+
+// S3Store keeps one object per session in an S3-compatible bucket.
+// Credentials, region and endpoint come from the standard AWS env vars
+// (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_REGION, AWS_ENDPOINT_URL...).
+type S3Store struct {
+	stdOut   chan string
+	client   *s3.Client
+	bucket   string
+	prefix   string
+	mu       sync.Mutex // guards manifest and records, which sync changes from a goroutine of its own
+	manifest s3Manifest
+	etag     string             // of the manifest as loaded; "" when there was none
+	records  map[uint32]Content // sessions as last read or written, see Get
+}
+
+func NewS3Store(stdOut chan string, bucket, prefix string) *S3Store {
+	return &S3Store{
+		stdOut: stdOut,
+		bucket: bucket,
+		prefix: prefix,
+		manifest: s3Manifest{
+			Sessions: map[uint32]int64{},
+		},
+		records: map[uint32]Content{},
+	}
+}
+
+func (s *S3Store) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + "/" + name
+}
+
+func (s *S3Store) sessionKey(id uint32) string {
+	return s.key(fmt.Sprintf("%s/%d.rec", S3_SESSION_DIR, id))
+}
+
+func (s *S3Store) versionDir(id uint32) string {
+	return s.key(fmt.Sprintf("%s/%d/", S3_VERSION_DIR, id))
+}
+
+func (s *S3Store) Check() error {
+	if s.client == nil {
+		return errors.New("s3 store is not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), S3_TIMEOUT)
+	defer cancel()
+
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	return err
+}
+
+func (s *S3Store) Initialize() error {
+	if s.bucket == "" {
+		return errors.New("no S3 bucket configured (storage.s3_bucket or RELAY_S3_BUCKET)")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), S3_TIMEOUT)
+	defer cancel()
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	// S3-compatible servers (minio, R2, ...) generally want path-style URLs.
+	customEndpoint := os.Getenv("AWS_ENDPOINT_URL_S3") != "" || os.Getenv("AWS_ENDPOINT_URL") != ""
+	s.client = s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.UsePathStyle = customEndpoint
+	})
+
+	s.mu.Lock()
+	err = s.loadManifest(ctx)
+	var noSuchKey *types.NoSuchKey
+	created := errors.As(err, &noSuchKey)
+	if created {
+		// another machine may be creating it too; then theirs is kept
+		err = s.updateManifest(ctx, func() {})
+	}
+	ids := make([]uint32, 0, len(s.manifest.Sessions))
+	for id := range s.manifest.Sessions {
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if created {
+		go func() {
+			s.stdOut <- fmt.Sprintf("Created manifest in s3://%s", s.bucket)
+		}()
+		return nil
+	}
+	s.prefetch(ids)
+	go func() {
+		s.stdOut <- fmt.Sprintf("Connected to s3://%s (%d sessions)", s.bucket, len(ids))
+	}()
+	return nil
+}
+
+// prefetch reads the sessions into records, S3_FETCHES at a time, so that
+// listing them later does not wait on a round trip per session. Failures
+// are left for Get to run into again.
+func (s *S3Store) prefetch(ids []uint32) {
+	queue := make(chan uint32)
+	var wg sync.WaitGroup
+	for range S3_FETCHES {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range queue {
+				s.Get(id)
+			}
+		}()
+	}
+	for _, id := range ids {
+		queue <- id
+	}
+	close(queue)
+	wg.Wait()
+}
+
+func (s *S3Store) loadManifest(ctx context.Context) error {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(S3_MANIFEST_NAME)),
+	})
+	if err != nil {
+		return err
+	}
+	defer out.Body.Close()
+
+	manifest := s3Manifest{Sessions: map[uint32]int64{}}
+	if err := json.NewDecoder(out.Body).Decode(&manifest); err != nil {
+		return err
+	}
+	if manifest.Sessions == nil {
+		manifest.Sessions = map[uint32]int64{}
+	}
+	s.manifest = manifest
+	s.etag = aws.ToString(out.ETag)
+	return nil
+}
+
+// saveManifest puts the manifest back only if nobody has changed it since
+// it was loaded, see updateManifest.
+func (s *S3Store) saveManifest(ctx context.Context) error {
+	data, err := json.Marshal(s.manifest)
+	if err != nil {
+		return err
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.key(S3_MANIFEST_NAME)),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}
+	if s.etag == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(s.etag)
+	}
+	out, err := s.client.PutObject(ctx, input)
+	if err != nil {
+		return err
+	}
+	s.etag = aws.ToString(out.ETag)
+	return nil
+}
+
+// updateManifest applies change to the manifest and saves it. Another
+// machine on the bucket may have saved one in between; then its manifest
+// is loaded and change applied to that instead.
+func (s *S3Store) updateManifest(ctx context.Context, change func()) error {
+	for attempt := 1; ; attempt++ {
+		change()
+		err := s.saveManifest(ctx)
+		if !lostRace(err) {
+			return err
+		}
+		if attempt == S3_ATTEMPTS {
+			return fmt.Errorf("the manifest in s3://%s keeps changing: %w", s.bucket, err)
+		}
+		if err := s.refreshManifest(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// refreshManifest loads the manifest as it is in the bucket now.
+func (s *S3Store) refreshManifest(ctx context.Context) error {
+	err := s.loadManifest(ctx)
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		s.manifest, s.etag = s3Manifest{Sessions: map[uint32]int64{}}, ""
+		return nil
+	}
+	return err
+}
+
+// lostRace says a conditional write failed because another writer got
+// there first.
+func lostRace(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	code := apiErr.ErrorCode()
+	return code == "PreconditionFailed" || code == "ConditionalRequestConflict"
+}
+
+func (s *S3Store) Store(id uint32, content Content) (uint32, error) {
+	if s.client == nil {
+		return 0, errors.New("s3 store is not initialized")
+	}
+	if err := checkRecordSize(content); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), S3_TIMEOUT)
+	defer cancel()
+
+	// Ids are handed out from the manifest as it is in the bucket now, and
+	// a new session's object is only put where there is none yet, so two
+	// machines never store under the same new id.
+	if err := s.refreshManifest(ctx); err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		var err error
+		if id, err = s.putNew(ctx, content); err != nil {
+			return 0, err
+		}
+	} else if err := s.putOver(ctx, id, content); err != nil {
+		return 0, err
+	}
+
+	err := s.updateManifest(ctx, func() {
+		s.manifest.Count = max(s.manifest.Count, id)
+		s.manifest.Sessions[id] = content.UpdatedAt
+	})
+	if err != nil {
+		return 0, err
+	}
+	content.Id, content.Length = id, uint32(len(content.Content))
+	s.records[id] = content
+
+	go func() {
+		s.stdOut <- fmt.Sprintf("Stored message with ID %d in s3://%s", id, s.bucket)
+	}()
+
+	return id, nil
+}
+
+// putNew stores content under the next id nobody has taken, and returns it.
+func (s *S3Store) putNew(ctx context.Context, content Content) (uint32, error) {
+	id := s.manifest.Count
+	for attempt := 1; ; attempt++ {
+		id++
+		_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(s.bucket),
+			Key:         aws.String(s.sessionKey(id)),
+			Body:        bytes.NewReader(encodeContent(id, content)),
+			ContentType: aws.String("application/octet-stream"),
+			IfNoneMatch: aws.String("*"),
+		})
+		if !lostRace(err) {
+			return id, err
+		}
+		if attempt == S3_ATTEMPTS {
+			return 0, fmt.Errorf("no free id in s3://%s: %w", s.bucket, err)
+		}
+	}
+}
+
+// putOver stores content under id, keeping the version it replaces and
+// dropping those beyond KEEP_VERSIONS.
+func (s *S3Store) putOver(ctx context.Context, id uint32, content Content) error {
+	// Keep the version we are about to replace under versions/<id>/.
+	if _, exists := s.manifest.Sessions[id]; exists {
+		_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:     aws.String(s.bucket),
+			CopySource: aws.String(url.PathEscape(s.bucket + "/" + s.sessionKey(id))),
+			Key:        aws.String(fmt.Sprintf("%s%020d.rec", s.versionDir(id), time.Now().UnixNano())),
+		})
+		if err != nil {
+			var noSuchKey *types.NoSuchKey
+			if !errors.As(err, &noSuchKey) {
+				return err
+			}
+		}
+		if err := s.pruneVersions(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.sessionKey(id)),
+		Body:        bytes.NewReader(encodeContent(id, content)),
+		ContentType: aws.String("application/octet-stream"),
+	})
+	return err
+}
+
+// pruneVersions deletes the oldest versions of id, leaving room for
+// KEEP_VERSIONS with the current one.
+func (s *S3Store) pruneVersions(ctx context.Context, id uint32) error {
+	keys, err := s.versionKeys(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys[:max(len(keys)-(KEEP_VERSIONS-1), 0)] {
+		_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *S3Store) Update(id uint32, content Content) error {
+	return updateRecord(s, id, content)
+}
+
+// Get answers from records while the manifest has the session at the
+// same UpdatedAt, and only goes to the bucket when it has changed.
+func (s *S3Store) Get(id uint32) (Content, error) {
+	if s.client == nil {
+		return Content{}, errors.New("s3 store is not initialized")
+	}
+	s.mu.Lock()
+	updatedAt, ok := s.manifest.Sessions[id]
+	cached, fresh := s.records[id]
+	s.mu.Unlock()
+	if !ok {
+		return Content{}, ErrNotFound
+	}
+	if fresh && cached.UpdatedAt == updatedAt {
+		return cached, nil
+	}
+
+	content, err := s.getObject(s.sessionKey(id))
+	if err != nil {
+		return Content{}, err
+	}
+	s.mu.Lock()
+	s.records[id] = content
+	s.mu.Unlock()
+	return content, nil
+}
+
+func (s *S3Store) GetVersions(id uint32) ([]Content, error) {
+	current, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), S3_TIMEOUT)
+	defer cancel()
+
+	keys, err := s.versionKeys(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]Content, 0, len(keys)+1)
+	for _, key := range keys {
+		content, err := s.getObject(key)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, content)
+	}
+	return append(versions, current), nil
+}
+
+// versionKeys lists versions/<id>/, oldest first.
+func (s *S3Store) versionKeys(ctx context.Context, id uint32) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.versionDir(id)),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *S3Store) Delete(id uint32) error {
+	return trashRecord(s, id)
+}
+
+func (s *S3Store) Restore(id uint32) error {
+	return restoreRecord(s, id)
+}
+
+func (s *S3Store) List() ([]Content, error) {
+	return listRecords(s)
+}
+
+func (s *S3Store) ListTrash() ([]Content, error) {
+	return listTrash(s)
+}
+
+func (s *S3Store) PurgeTrash(grace time.Duration) (int, error) {
+	expired, err := expiredTrash(s, grace)
+	if err != nil || len(expired) == 0 {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), S3_TIMEOUT)
+	defer cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refreshManifest(ctx); err != nil {
+		return 0, err
+	}
+	for _, id := range expired {
+		keys, err := s.versionKeys(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, key := range append(keys, s.sessionKey(id)) {
+			_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket: aws.String(s.bucket),
+				Key:    aws.String(key),
+			})
+			if err != nil {
+				return 0, err
+			}
+		}
+		delete(s.records, id)
+	}
+
+	err = s.updateManifest(ctx, func() {
+		for _, id := range expired {
+			delete(s.manifest.Sessions, id)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	go func() {
+		s.stdOut <- fmt.Sprintf("Purged %d chats from the trash", len(expired))
+	}()
+	return len(expired), nil
+}
+
+func (s *S3Store) getObject(key string) (Content, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), S3_TIMEOUT)
+	defer cancel()
+
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return Content{}, ErrNotFound
+		}
+		return Content{}, err
+	}
+	defer out.Body.Close()
+
+	buffer, err := io.ReadAll(out.Body)
+	if err != nil {
+		return Content{}, err
+	}
+	return decodeContent(buffer)
+}
+
+func (s *S3Store) GetIds() ([]uint32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]uint32, 0, len(s.manifest.Sessions))
+	for id := range s.manifest.Sessions {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+type searchHit struct {
+	session Session
+	index   int // message index within the session
+	snippet string
+}
+
+// search looks for text in every stored session. Enter runs the query and,
+// once the results are up to date, opens the selected match.
+type search struct {
+	input  textinput.Model
+	query  string
+	hits   []searchHit
+	cursor int
+}
+
+func searchSessions(storage Store, query string) ([]searchHit, error) {
+	sessions, err := listSessions(storage, true)
+	if err != nil {
+		return nil, err
+	}
+
+	needle := strings.ToLower(query)
+	hits := []searchHit{}
+	for _, session := range sessions {
+		for i, message := range session.Messages {
+			if strings.Contains(strings.ToLower(message.Text), needle) {
+				hits = append(hits, searchHit{session: session, index: i, snippet: snippet(message, needle)})
+			}
+		}
+	}
+	return hits, nil
+}
+
+// snippet is the line holding the first match, starting a little before it
+// when the line is long.
+func snippet(message Message, needle string) string {
+	for _, line := range strings.Split(message.Text, "\n") {
+		at := strings.Index(strings.ToLower(line), needle)
+		if at < 0 {
+			continue
+		}
+		if skip := ansi.StringWidth(strings.ToLower(line)[:at]) - 20; skip > 0 {
+			line = ansi.TruncateLeft(line, skip, "…")
+		}
+		return message.Role.Label() + ": " + line
+	}
+	return message.Role.Label() + ": " + message.Text
+}
+
+func (m model) openSearch() (model, tea.Cmd) {
+	ti := textinput.New()
+	ti.Prompt = "Search: "
+	ti.Focus()
+	m.search = search{input: ti}
+	m.mode = modeSearch
+	m.textarea.Blur()
+	return m, textinput.Blink
+}
+
+func (m model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		return m.openSessions()
+	case "up", "ctrl+p":
+		if m.search.cursor > 0 {
+			m.search.cursor--
+		}
+		return m, nil
+	case "down", "ctrl+n":
+		if m.search.cursor < len(m.search.hits)-1 {
+			m.search.cursor++
+		}
+		return m, nil
+	case "enter":
+		query := strings.TrimSpace(m.search.input.Value())
+		if query == "" {
+			return m, nil
+		}
+		if query != m.search.query {
+			hits, err := searchSessions(m.storage, query)
+			if err != nil {
+				m.addMessage(RoleSystem, "Error searching sessions: "+err.Error())
+			}
+			m.search.query = query
+			m.search.hits = hits
+			m.search.cursor = 0
+			return m, nil
+		}
+		if len(m.search.hits) == 0 {
+			return m, nil
+		}
+
+		hit := m.search.hits[m.search.cursor]
+		m = m.closeOverlay()
+		if err := m.loadSession(hit.session.Id); err != nil {
+			m.addMessage(RoleSystem, "Error loading session: "+err.Error())
+			return m, nil
+		}
+		m.viewport.SetYOffset(m.messageOffset(hit.index))
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.search.input, cmd = m.search.input.Update(msg)
+	return m, cmd
+}
+
+// messageOffset is the viewport line where messages[i] starts. The lines
+// are counted when the transcript is drawn; should the messages have
+// changed since, they are laid out again.
+func (m model) messageOffset(i int) int {
+	offsets := m.offsets
+	if len(offsets) != len(m.messages)+1 {
+		_, offsets = layoutTranscript(m.messages, m.transcriptOptions())
+	}
+	return offsets[min(i, len(offsets)-1)]
+}
+
+// searchView lists the matches under a heading per session.
+func (m model) searchView(width, height int) string {
+	lines := []string{
+		menuTitleStyle.Render("Search all sessions (enter to search/open, esc to go back)"),
+		"",
+		m.search.input.View(),
+		"",
+	}
+	if m.search.query != "" && len(m.search.hits) == 0 {
+		lines = append(lines, menuDetailStyle.Render("(no matches)"))
+	}
+
+	var rows []string
+	cursorRow := 0
+	for i, hit := range m.search.hits {
+		if i == 0 || hit.session.Id != m.search.hits[i-1].session.Id {
+			rows = append(rows, fmt.Sprintf("#%d %s", hit.session.Id, hit.session.Name()))
+		}
+		text := ansi.Truncate(strings.ReplaceAll(hit.snippet, "\n", " "), width-4, "…")
+		if i == m.search.cursor {
+			cursorRow = len(rows)
+			rows = append(rows, menuSelectedStyle.Render("  > "+text))
+		} else {
+			rows = append(rows, "    "+menuDetailStyle.Render(text))
+		}
+	}
+
+	// keep the selected match on screen
+	visible := max(height-len(lines), 1)
+	start := 0
+	if cursorRow >= visible {
+		start = cursorRow - visible + 1
+	}
+	lines = append(lines, rows[start:min(start+visible, len(rows))]...)
+	return strings.Join(lines, "\n")
+}
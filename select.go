@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Select mode (ctrl+l) moves a cursor over the messages of the current chat
+// so actions can be applied to one of them.
+
+func (m model) openSelect() (model, tea.Cmd) {
+	if len(m.messages) == 0 {
+		m.addMessage(RoleSystem, "No messages to select")
+		return m, nil
+	}
+
+	m.mode = modeSelect
+	m.selected = len(m.messages) - 1
+	m.textarea.Blur()
+	m.showSelected()
+	return m, nil
+}
+
+func (m model) closeSelect() model {
+	m = m.closeOverlay()
+	m.refreshTranscript()
+	return m
+}
+
+// showSelected re-renders the transcript with the cursor and scrolls it
+// into view.
+func (m *model) showSelected() {
+	m.refreshTranscript()
+
+	top := m.messageOffset(m.selected)
+	bottom := m.messageOffset(m.selected+1) - 1
+	if top < m.viewport.YOffset {
+		m.viewport.SetYOffset(top)
+	} else if bottom >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(bottom - m.viewport.Height + 1)
+	}
+}
+
+// moveSelected steps over messages folded away by /summarize collapse.
+func (m *model) moveSelected(step int) {
+	for i := m.selected + step; i >= 0 && i < len(m.messages); i += step {
+		if !m.messages[i].Collapsed {
+			m.selected = i
+			m.showSelected()
+			return
+		}
+	}
+}
+
+func (m model) updateSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	keys := m.keys.Select
+	switch {
+	case key.Matches(msg, keys.Back):
+		return m.closeSelect(), nil
+	case key.Matches(msg, keys.Up):
+		m.moveSelected(-1)
+	case key.Matches(msg, keys.Down):
+		m.moveSelected(1)
+	case key.Matches(msg, keys.Find):
+		return m.closeSelect().openFind()
+	case key.Matches(msg, keys.Help):
+		return m.openHelp()
+	case key.Matches(msg, keys.Fork):
+		if m.cliLoading {
+			return m, nil
+		}
+		m = m.closeSelect()
+		m.fork(m.selected)
+	case key.Matches(msg, keys.Edit, keys.Reuse):
+		if m.cliLoading {
+			return m, nil
+		}
+		message := m.messages[m.selected]
+		if message.Role != RoleUser {
+			return m, m.showToast("Only your own messages can be edited")
+		}
+		m = m.closeSelect()
+		m.editing = 0
+		if key.Matches(msg, keys.Edit) {
+			m.editing = m.selected + 1
+		}
+		m.textarea.SetValue(message.Text)
+	case key.Matches(msg, keys.Compare):
+		return m.markCompare()
+	case key.Matches(msg, keys.Bookmark):
+		return m, m.toggleBookmark(m.selected)
+	case key.Matches(msg, keys.Expand):
+		if m.expanded == nil {
+			m.expanded = map[int]bool{}
+		}
+		m.expanded[m.selected] = !m.expanded[m.selected]
+		m.showSelected()
+	case key.Matches(msg, keys.Delete, keys.DeletePair):
+		if m.cliLoading {
+			return m, nil
+		}
+		from, to := m.selected, m.selected+1
+		if key.Matches(msg, keys.DeletePair) {
+			from, to = m.exchange(m.selected)
+		}
+		m.deleteMessages(from, to)
+		if len(m.messages) == 0 {
+			return m.closeSelect(), nil
+		}
+		m.selected = min(from, len(m.messages)-1)
+		m.showSelected()
+	}
+	return m, nil
+}
+
+// exchange returns the range holding message i and the message it pairs
+// with: a prompt and its reply.
+func (m model) exchange(i int) (int, int) {
+	switch role := m.messages[i].Role; {
+	case role == RoleUser && i+1 < len(m.messages) && m.messages[i+1].Role == RoleBot:
+		return i, i + 2
+	case role == RoleBot && i > 0 && m.messages[i-1].Role == RoleUser:
+		return i - 1, i + 1
+	}
+	return i, i + 1
+}
+
+// deleteMessages removes messages [from, to) and stores the chat without
+// them.
+func (m *model) deleteMessages(from, to int) {
+	m.messages = append(m.messages[:from], m.messages[to:]...)
+	m.expanded = nil
+	m.compare.mark = 0
+	m.meta.Summary, m.meta.Summarized = "", 0
+	m.changed()
+	switch {
+	case m.editing > to:
+		m.editing -= to - from
+	case m.editing > from:
+		m.editing = 0
+	}
+	if m.autosave && m.currentId != 0 {
+		m.save()
+	}
+}
+
+// resend drops the message being edited and everything after it, so the
+// edited text goes out in its place and the chat carries on from there.
+func (m *model) resend() {
+	at := m.editing - 1
+	m.editing = 0
+	if at < 0 || at >= len(m.messages) || m.messages[at].Role != RoleUser {
+		return
+	}
+	m.messages = m.messages[:at]
+	m.changed()
+}
+
+// fork starts a new session holding the transcript up to and including
+// message i. The chat it came from is saved first and left as it was.
+func (m *model) fork(i int) {
+	origin, err := saveChatHistoryToFile(m.currentId, m.transcript(), m.storage)
+	if err != nil {
+		m.addMessage(RoleSystem, "Could not save the current chat; not forking: "+err.Error())
+		return
+	}
+	m.currentId = origin
+
+	name := Session{Title: m.meta.Title, Messages: m.messages}.Name()
+	m.messages = append([]Message(nil), m.messages[:i+1]...)
+	m.meta.Title = "Fork of " + name
+	m.meta.ForkOf = origin
+	m.meta.Archived, m.meta.Pinned = false, false
+	m.keepResume("")
+	m.changed()
+	// the fork is a new chat from here on, saved or not
+	m.currentId = 0
+	m.save()
+	m.addMessage(RoleSystem, fmt.Sprintf("Forked from #%d %q at message %d", origin, name, i+1))
+	m.refreshSidebar()
+}
+
+// openJump lists the user's prompts in the current chat; enter scrolls the
+// transcript to the chosen one.
+func (m model) openJump() (model, tea.Cmd) {
+	items := []menuItem{}
+	for i, message := range m.messages {
+		if message.Role != RoleUser || message.Collapsed {
+			continue
+		}
+		line, _, _ := strings.Cut(message.Text, "\n")
+		items = append(items, menuItem{
+			title:  line,
+			detail: fmt.Sprintf("line %d", m.messageOffset(i)+1),
+			id:     uint32(i),
+		})
+	}
+	if len(items) == 0 {
+		m.addMessage(RoleSystem, "No prompts to jump to")
+		return m, nil
+	}
+
+	m.menu = menu{title: "Jump to message", hint: "enter jump · esc close", items: items, cursor: len(items) - 1}
+	m.mode = modeJump
+	m.textarea.Blur()
+	return m, nil
+}
+
+func (m model) updateJump(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "ctrl+g":
+		return m.closeOverlay(), nil
+	case "up", "k":
+		m.menu.up()
+	case "down", "j":
+		m.menu.down()
+	case "enter":
+		item, ok := m.menu.selected()
+		m = m.closeOverlay()
+		if ok {
+			m.viewport.SetYOffset(m.messageOffset(int(item.id)))
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Session is a stored chat: one record whose transcript is not a
+// checkpoint.
+type Session struct {
+	Id        uint32
+	CreatedAt int64
+	UpdatedAt int64
+	Title     string
+	Archived  bool
+	Pinned    bool
+	Tags      []string
+	Messages  []Message
+}
+
+func sessionFromContent(content Content) Session {
+	t := decodeTranscript(content.Content)
+	return Session{
+		Id:        content.Id,
+		CreatedAt: content.CreatedAt,
+		UpdatedAt: content.UpdatedAt,
+		Title:     t.Title,
+		Archived:  t.Archived,
+		Pinned:    t.Pinned,
+		Tags:      t.Tags,
+		Messages:  t.Messages,
+	}
+}
+
+// Name is the session's title, falling back to its preview.
+func (s Session) Name() string {
+	if s.Title != "" {
+		return s.Title
+	}
+	return s.Preview()
+}
+
+// Preview is the first thing the user said, cut to fit a list row.
+func (s Session) Preview() string {
+	for _, message := range s.Messages {
+		if message.Role == RoleUser {
+			line, _, _ := strings.Cut(message.Text, "\n")
+			return ansi.Truncate(line, 40, "…")
+		}
+	}
+	return "(no messages)"
+}
+
+// listSessions returns stored sessions, pinned ones first and then the most
+// recently updated.
+// Archived sessions are left out unless archived is set.
+func listSessions(storage Store, archived bool) ([]Session, error) {
+	records, err := storage.List()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []Session{}
+	for _, content := range records {
+		if decodeTranscript(content.Content).Label != "" {
+			continue
+		}
+		session := sessionFromContent(content)
+		if session.Archived && !archived {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		if sessions[i].Pinned != sessions[j].Pinned {
+			return sessions[i].Pinned
+		}
+		return sessions[i].UpdatedAt > sessions[j].UpdatedAt
+	})
+	return sessions, nil
+}
+
+// newSession stores the current chat (if there is anything to keep) and
+// starts over with an empty one; the next save creates a new record. It
+// reports false when the current chat could not be saved.
+func (m *model) newSession() bool {
+	if len(m.messages) > 0 {
+		if _, err := saveChatHistoryToFile(m.currentId, m.transcript(), m.storage); err != nil {
+			m.addMessage(RoleSystem, "Could not save the current chat; not starting a new one: "+err.Error())
+			return false
+		}
+	}
+
+	m.dropResponse()
+	m.messages = []Message{}
+	m.meta = m.freshMeta()
+	m.currentId = 0
+	m.editing = 0
+	m.expanded = nil
+	m.compare.mark = 0
+	m.markSaved()
+	m.viewport.SetContent("New chat started. Type a message below.")
+	m.refreshSidebar()
+	return true
+}
+
+// loadSession opens a stored session in place of the current chat, which is
+// saved first when it has changes; if that fails the chat stays open.
+func (m *model) loadSession(id uint32) error {
+	content, err := m.storage.Get(id)
+	if err != nil {
+		return err
+	}
+	if len(m.messages) > 0 && m.dirty() {
+		if err := m.trySave(); err != nil {
+			return fmt.Errorf("the current chat could not be saved: %w", err)
+		}
+	}
+
+	m.dropResponse()
+	t := decodeTranscript(content.Content)
+	m.messages = t.Messages
+	m.meta = t.sessionMeta
+	if m.meta.Provider == "" {
+		// saved before sessions remembered their backend
+		m.meta.Provider, m.meta.Model = m.chat.Provider, m.chat.Model
+	}
+	m.currentId = id
+	m.editing = 0
+	m.expanded = nil
+	m.compare.mark = 0
+	m.markSaved()
+	m.refreshTranscript()
+	m.viewport.GotoBottom()
+	m.refreshSidebar()
+	return nil
+}
+
+// freshMeta is the meta of a new chat: no title yet, the configured
+// backend.
+func (m model) freshMeta() sessionMeta {
+	return sessionMeta{Provider: m.chat.Provider, Model: m.chat.Model}
+}
+
+func (m model) transcript() transcript {
+	return transcript{Messages: m.messages, sessionMeta: m.meta}
+}
+
+// rename sets the current session's title, storing it right away when the
+// chat has been saved before.
+func (m *model) rename(title string) {
+	m.meta.Title = title
+	m.changed()
+	if m.currentId != 0 {
+		if err := m.storage.Update(m.currentId, transcriptToContent(m.transcript())); err != nil {
+			m.addMessage(RoleSystem, "Error renaming session: "+err.Error())
+			return
+		}
+		m.markSaved()
+	}
+	m.refreshSidebar()
+}
+
+// resumeLatest loads the most recently updated session, if any.
+func (m *model) resumeLatest() {
+	sessions, err := listSessions(m.storage, false)
+	if err != nil || len(sessions) == 0 {
+		return
+	}
+	// the list has pinned sessions first
+	latest := slices.MaxFunc(sessions, func(a, b Session) int { return cmp.Compare(a.UpdatedAt, b.UpdatedAt) })
+	if err := m.loadSession(latest.Id); err != nil {
+		m.addMessage(RoleSystem, fmt.Sprintf("Error resuming session #%d: %v", latest.Id, err))
+	}
+}
+
+// editMeta changes a stored session's meta (archived, pinned, ...), keeping
+// the current chat's meta in step when it is the one loaded.
+func (m *model) editMeta(id uint32, edit func(meta *sessionMeta)) error {
+	content, err := m.storage.Get(id)
+	if err != nil {
+		return err
+	}
+
+	t := decodeTranscript(content.Content)
+	edit(&t.sessionMeta)
+	if err := m.storage.Update(id, transcriptToContent(t)); err != nil {
+		return err
+	}
+	if id == m.currentId {
+		// stored already, so it leaves the chat as saved as it was
+		m.meta = t.sessionMeta
+	}
+	return nil
+}
+
+// deleteSession moves a session to the trash. Deleting the open chat leaves
+// an empty one behind instead of saving it again under a new id.
+func (m *model) deleteSession(id uint32) error {
+	if err := m.storage.Delete(id); err != nil {
+		return err
+	}
+	if id == m.currentId {
+		m.messages = []Message{}
+		m.meta = m.freshMeta()
+		m.currentId = 0
+		m.markSaved()
+		m.viewport.SetContent("Session deleted. Type a message below to start a new one.")
+	}
+	return nil
+}
+
+// mergeSessions moves the messages of session from into session into,
+// interleaved by time, and sends from to the trash. Messages saved before
+// they carried a time count as sent when their session was created.
+func mergeSessions(storage Store, into, from uint32) error {
+	if into == from {
+		return fmt.Errorf("cannot merge session #%d into itself", into)
+	}
+
+	target, err := storage.Get(into)
+	if err != nil {
+		return err
+	}
+	source, err := storage.Get(from)
+	if err != nil {
+		return err
+	}
+
+	t := decodeTranscript(target.Content)
+	other := decodeTranscript(source.Content)
+	type timed struct {
+		Message
+		at int64
+	}
+	merged := make([]timed, 0, len(t.Messages)+len(other.Messages))
+	for _, message := range t.Messages {
+		merged = append(merged, timed{message, cmp.Or(message.Time, target.CreatedAt)})
+	}
+	for _, message := range other.Messages {
+		merged = append(merged, timed{message, cmp.Or(message.Time, source.CreatedAt)})
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].at < merged[j].at })
+
+	t.Messages = t.Messages[:0]
+	for _, message := range merged {
+		t.Messages = append(t.Messages, message.Message)
+	}
+	if t.Title == "" {
+		t.Title = other.Title
+	}
+	// the messages it covered are interleaved with others now
+	t.Summary, t.Summarized = "", 0
+	if err := storage.Update(into, transcriptToContent(t)); err != nil {
+		return err
+	}
+	return storage.Delete(from)
+}
+
+// mergeInto merges the session picked with 'm' into id. The open chat is
+// saved first when it takes part, and shows the merged session afterwards.
+func (m *model) mergeInto(id uint32) {
+	from := m.mergeFrom
+	m.mergeFrom = 0
+
+	involved := m.currentId == id || m.currentId == from
+	if involved && m.save() != nil {
+		// the notice says why
+		return
+	}
+	if err := mergeSessions(m.storage, id, from); err != nil {
+		m.addMessage(RoleSystem, "Error merging sessions: "+err.Error())
+		return
+	}
+	if involved {
+		if err := m.loadSession(id); err != nil {
+			m.addMessage(RoleSystem, "Error loading session: "+err.Error())
+		}
+	}
+	m.addMessage(RoleSystem, fmt.Sprintf("Merged session #%d into #%d", from, id))
+	m.refreshSidebar()
+}
+
+// cloneSession stores a copy of a session under a new id.
+func cloneSession(storage Store, id uint32) (uint32, error) {
+	content, err := storage.Get(id)
+	if err != nil {
+		return 0, err
+	}
+
+	t := decodeTranscript(content.Content)
+	t.Title = Session{Title: t.Title, Messages: t.Messages}.Name() + " (copy)"
+	t.Archived = false
+	// the copy must not carry on the backend's conversation of the original
+	t.Resume, t.ResumeAt = "", 0
+	return storage.Store(0, transcriptToContent(t))
+}
+
+func (m model) openSessions() (model, tea.Cmd) {
+	m.refreshSessions()
+	m.mode = modeSessions
+	m.textarea.Blur()
+	return m, nil
+}
+
+// refreshSessions rebuilds the picker's items, keeping the cursor in place.
+func (m *model) refreshSessions() {
+	sessions, err := listSessions(m.storage, m.archived)
+	if err != nil {
+		m.addMessage(RoleSystem, "Error listing sessions: "+err.Error())
+	}
+
+	items := make([]menuItem, 0, len(sessions))
+	for _, session := range sessions {
+		if !matchesFilter(session, m.filter.Value()) {
+			continue
+		}
+		title := fmt.Sprintf("#%d %s", session.Id, session.Name())
+		if session.Pinned {
+			title = "★ " + title
+		}
+		if session.Archived {
+			title += " [archived]"
+		}
+		if session.Id == m.currentId {
+			title += " (current)"
+		}
+		if session.Id == m.mergeFrom {
+			title += " (merging)"
+		}
+		detail := fmt.Sprintf("%s, %d messages", time.Unix(session.UpdatedAt, 0).Format("2006-01-02 15:04"), len(session.Messages))
+		if len(session.Tags) > 0 {
+			detail += " #" + strings.Join(session.Tags, " #")
+		}
+		items = append(items, menuItem{
+			title:  title,
+			detail: detail,
+			id:     session.Id,
+		})
+	}
+
+	title, hint := "Sessions", "enter open · / filter · p pin · c clone · a archive · d delete · m merge · A show archived · ctrl+f search · esc close"
+	if m.archived {
+		title, hint = "All sessions", "enter open · / filter · p pin · c clone · a archive/unarchive · d delete · m merge · A hide archived · ctrl+f search · esc close"
+	}
+	if m.mergeFrom != 0 {
+		hint = fmt.Sprintf("m merge #%d into the selected session · esc cancel", m.mergeFrom)
+	}
+	cursor := 0
+	if m.mode == modeSessions {
+		cursor = min(m.menu.cursor, max(len(items)-1, 0))
+	}
+	m.menu = menu{title: title, hint: hint, items: items, cursor: cursor}
+}
+
+func (m model) updateSessions(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.filtering {
+		return m.updateFilter(msg)
+	}
+
+	switch msg.String() {
+	case "/":
+		return m.openFilter()
+	case "c":
+		item, ok := m.menu.selected()
+		if !ok {
+			return m, nil
+		}
+		if item.id == m.currentId {
+			m.save()
+		}
+		id, err := cloneSession(m.storage, item.id)
+		if err != nil {
+			m.addMessage(RoleSystem, "Error cloning session: "+err.Error())
+		}
+		m.refreshSessions()
+		m.menu.cursor = m.menu.find(id)
+		m.refreshSidebar()
+	case "esc", "ctrl+o":
+		if m.mergeFrom != 0 {
+			m.mergeFrom = 0
+			m.refreshSessions()
+			return m, nil
+		}
+		return m.closeOverlay(), nil
+	case "ctrl+f":
+		return m.openSearch()
+	case "m":
+		item, ok := m.menu.selected()
+		if !ok {
+			return m, nil
+		}
+		if m.mergeFrom == 0 {
+			m.mergeFrom = item.id
+		} else if m.mergeFrom != item.id {
+			m.mergeInto(item.id)
+		}
+		m.refreshSessions()
+		m.menu.cursor = m.menu.find(item.id)
+	case "up", "k":
+		m.menu.up()
+	case "down", "j":
+		m.menu.down()
+	case "enter":
+		item, ok := m.menu.selected()
+		m = m.closeOverlay()
+		if !ok {
+			return m, nil
+		}
+		if err := m.loadSession(item.id); err != nil {
+			m.addMessage(RoleSystem, "Error loading session: "+err.Error())
+		}
+	case "a":
+		item, ok := m.menu.selected()
+		if !ok {
+			return m, nil
+		}
+		err := m.editMeta(item.id, func(meta *sessionMeta) { meta.Archived = !meta.Archived })
+		if err != nil {
+			m.addMessage(RoleSystem, "Error archiving session: "+err.Error())
+		}
+		m.refreshSessions()
+		m.refreshSidebar()
+	case "p":
+		item, ok := m.menu.selected()
+		if !ok {
+			return m, nil
+		}
+		err := m.editMeta(item.id, func(meta *sessionMeta) { meta.Pinned = !meta.Pinned })
+		if err != nil {
+			m.addMessage(RoleSystem, "Error pinning session: "+err.Error())
+		}
+		m.refreshSessions()
+		m.menu.cursor = m.menu.find(item.id)
+		m.refreshSidebar()
+	case "A":
+		m.archived = !m.archived
+		m.refreshSessions()
+	case "d":
+		item, ok := m.menu.selected()
+		if !ok {
+			return m, nil
+		}
+		if err := m.deleteSession(item.id); err != nil {
+			m.addMessage(RoleSystem, "Error deleting session: "+err.Error())
+		}
+		m.refreshSessions()
+		m.refreshSidebar()
+	}
+	return m, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const SIDEBAR_WIDTH = 30
+
+var sidebarStyle = lipgloss.NewStyle().
+	Border(lipgloss.RoundedBorder()).
+	Padding(0, 1)
+
+// sidebar lists stored sessions to the left of the transcript. It only
+// takes keys while focused; ctrl+b shows/focuses it and hides it again, tab
+// passes the focus on to the input.
+type sidebar struct {
+	visible bool
+	focused bool
+	menu    menu
+}
+
+func (m *model) refreshSidebar() {
+	if !m.sidebar.visible {
+		return
+	}
+
+	sessions, err := listSessions(m.storage, false)
+	if err != nil {
+		m.addMessage(RoleSystem, "Error listing sessions: "+err.Error())
+		return
+	}
+
+	items := make([]menuItem, 0, len(sessions))
+	for _, session := range sessions {
+		title := session.Name()
+		if session.Pinned {
+			title = "★ " + title
+		}
+		items = append(items, menuItem{
+			title:  title,
+			detail: fmt.Sprintf("#%d %s · %d msgs", session.Id, time.Unix(session.UpdatedAt, 0).Format("01-02 15:04"), len(session.Messages)),
+			id:     session.Id,
+		})
+	}
+
+	cursor := min(m.sidebar.menu.cursor, max(len(items)-1, 0))
+	m.sidebar.menu = menu{title: "Sessions", items: items, cursor: cursor}
+}
+
+func (m model) toggleSidebar() (model, tea.Cmd) {
+	switch {
+	case !m.sidebar.visible:
+		m.sidebar.visible = true
+		m.sidebar.focused = true
+		m.textarea.Blur()
+		m.refreshSidebar()
+	case m.sidebar.focused:
+		m.sidebar.visible = false
+		m.sidebar.focused = false
+		m.textarea.Focus()
+	default:
+		m.sidebar.focused = true
+		m.textarea.Blur()
+	}
+	m.resize()
+	return m, nil
+}
+
+func (m model) updateSidebar(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+b":
+		return m.toggleSidebar()
+	case "esc", "tab":
+		m.sidebar.focused = false
+		m.textarea.Focus()
+	case "up", "k":
+		m.sidebar.menu.up()
+	case "down", "j":
+		m.sidebar.menu.down()
+	case "enter":
+		item, ok := m.sidebar.menu.selected()
+		if !ok {
+			return m, nil
+		}
+		if err := m.loadSession(item.id); err != nil {
+			m.addMessage(RoleSystem, "Error loading session: "+err.Error())
+		}
+		m.sidebar.focused = false
+		m.textarea.Focus()
+	}
+	return m, nil
+}
+
+// sidebarView renders the pane at the given total height so it lines up
+// with the transcript box.
+func (m model) sidebarView(height int) string {
+	width := SIDEBAR_WIDTH - sidebarStyle.GetHorizontalFrameSize()
+	height -= sidebarStyle.GetVerticalFrameSize()
+	title := menuTitleStyle.Render("Sessions")
+	if m.sidebar.focused {
+		title = menuSelectedStyle.Render("Sessions")
+	}
+
+	lines := []string{title, ""}
+	mn := m.sidebar.menu
+	if len(mn.items) == 0 {
+		lines = append(lines, menuDetailStyle.Render("(no saved sessions)"))
+	}
+
+	// two lines per session; scroll so the cursor stays visible
+	visible := max((height-2)/2, 1)
+	start := 0
+	if mn.cursor >= visible {
+		start = mn.cursor - visible + 1
+	}
+	for i := start; i < min(start+visible, len(mn.items)); i++ {
+		item := mn.items[i]
+		title := ansi.Truncate(item.title, width-2, "…")
+		if i == mn.cursor && m.sidebar.focused {
+			title = menuSelectedStyle.Render("> " + title)
+		} else if item.id == m.currentId {
+			title = menuSelectedStyle.Render("* ") + title
+		} else {
+			title = "  " + title
+		}
+		lines = append(lines, title, "  "+menuDetailStyle.Render(ansi.Truncate(item.detail, width-2, "…")))
+	}
+
+	return sidebarStyle.
+		Width(SIDEBAR_WIDTH - sidebarStyle.GetHorizontalBorderSize()).
+		Height(height).
+		Render(strings.Join(lines, "\n"))
+}
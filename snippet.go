@@ -0,0 +1,72 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Snippet is a prompt to insert into the input (ctrl+p). {{name}} marks a
+// field to fill in: the cursor starts where the first one was and the
+// others stay as reminders.
+type Snippet struct {
+	Name string `json:"name"`
+	Text string `json:"text"`
+}
+
+var snippetField = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+
+// insertSnippet types the snippet at the cursor and moves the cursor to its
+// first field.
+func (m *model) insertSnippet(snippet Snippet) {
+	at := snippetField.FindStringIndex(snippet.Text)
+	if at == nil {
+		m.textarea.InsertString(snippet.Text)
+		return
+	}
+
+	m.textarea.InsertString(snippet.Text[:at[0]])
+	row, info := m.textarea.Line(), m.textarea.LineInfo()
+	col := info.StartColumn + info.ColumnOffset
+
+	m.textarea.InsertString(snippet.Text[at[1]:])
+	for m.textarea.Line() > row {
+		m.textarea.CursorUp()
+	}
+	m.textarea.SetCursor(col)
+}
+
+func (m model) openSnippets() (model, tea.Cmd) {
+	if len(m.snippets) == 0 {
+		return m, m.showToast("No snippets yet: add them under \"snippets\" in " + configPath())
+	}
+
+	items := make([]menuItem, 0, len(m.snippets))
+	for i, snippet := range m.snippets {
+		line, _, _ := strings.Cut(snippet.Text, "\n")
+		items = append(items, menuItem{title: snippet.Name, detail: line, id: uint32(i)})
+	}
+	m.menu = menu{title: "Insert a snippet", hint: "enter insert · esc close", items: items}
+	m.mode = modeSnippets
+	m.textarea.Blur()
+	return m, nil
+}
+
+func (m model) updateSnippets(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "ctrl+p":
+		return m.closeOverlay(), nil
+	case "up", "k":
+		m.menu.up()
+	case "down", "j":
+		m.menu.down()
+	case "enter":
+		item, ok := m.menu.selected()
+		m = m.closeOverlay()
+		if ok {
+			m.insertSnippet(m.snippets[item.id])
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// While the backend works the status bar spins with the time spent so far
+// and how much has streamed in. The input stays open; what is sent in the
+// meantime waits in the queue.
+
+// QUIET_AFTER is how long the backend may go without output before the
+// status bar says so; a hung CLI looks different from a slow one.
+const QUIET_AFTER = 15 * time.Second
+
+func newSpinner() spinner.Model {
+	return spinner.New(spinner.WithSpinner(spinner.Dot), spinner.WithStyle(statusBusyStyle))
+}
+
+// startLoading marks a new request as pending. Its context is cancelled
+// when the request ends, is cancelled with esc, the chat is switched or
+// relay quits; replies tagged with an older m.request are dropped.
+func (m *model) startLoading() (context.Context, tea.Cmd) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.cancel = cancel
+	m.request++
+	m.cliLoading = true
+	m.sentAt = time.Now()
+	m.heardAt = m.sentAt
+	return ctx, m.spinner.Tick
+}
+
+func (m *model) stopLoading() {
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	m.cliLoading = false
+}
+
+// loadingStatus reads like "⣾ thinking… 4s · esc cancels" until the first
+// output and "⣾ streaming… 9s · ~120 tokens · esc cancels" after; a
+// request held back by the rate limit counts down to when it goes out.
+func (m model) loadingStatus() string {
+	if wait := time.Until(m.sentAt); wait > 0 {
+		status := fmt.Sprintf("rate limited, sending in %s · esc cancels", wait.Round(time.Second))
+		return m.spinner.View() + statusBusyStyle.Render(status)
+	}
+	elapsed := time.Since(m.sentAt).Truncate(time.Second)
+	status := fmt.Sprintf("thinking… %s", elapsed)
+	if m.partial != "" {
+		status = fmt.Sprintf("streaming… %s · ~%d tokens", elapsed, estimateTokens(m.partial))
+	}
+	if quiet := time.Since(m.heardAt).Truncate(time.Second); quiet >= QUIET_AFTER {
+		status += fmt.Sprintf(" · no output for %s", quiet)
+	}
+	status += " · esc cancels"
+	return m.spinner.View() + statusBusyStyle.Render(status)
+}
+
+// cancelResponse kills the pending request. Queued messages go back into
+// the input instead of being sent.
+func (m *model) cancelResponse() {
+	m.dropResponse()
+	m.addMessage(RoleSystem, "Cancelled")
+}
+
+// dropResponse kills the pending request, if there is one, without a word
+// in the chat; switching chats leaves the old one as it was.
+func (m *model) dropResponse() {
+	m.stopLoading()
+	m.stream, m.partial = nil, ""
+	m.unqueue()
+}
+
+// stopBackends cancels whatever is still running when relay exits and
+// waits until it is killed, since the commands run in their own process
+// groups and would not get the terminal's signals.
+func (m model) stopBackends() {
+	m.shutdown()
+	running.Wait()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Usage is what an exchange cost in tokens: the prompt, history included,
+// and the response. Estimated when the backend did not say; see
+// estimateTokens. Cost is in dollars, see exchangeCost, and 0 when the
+// model's price is not known.
+type Usage struct {
+	Prompt     int     `json:"prompt"`
+	Completion int     `json:"completion"`
+	Estimated  bool    `json:"estimated,omitempty"`
+	Cost       float64 `json:"cost_usd,omitempty"`
+}
+
+func (u Usage) add(other Usage) Usage {
+	return Usage{
+		Prompt:     u.Prompt + other.Prompt,
+		Completion: u.Completion + other.Completion,
+		Estimated:  u.Estimated || other.Estimated,
+		Cost:       u.Cost + other.Cost,
+	}
+}
+
+func (u Usage) Total() int {
+	return u.Prompt + u.Completion
+}
+
+// estimatedUsage stands in for backends that report nothing.
+func estimatedUsage(prompt, response string) Usage {
+	return Usage{Prompt: estimateTokens(prompt), Completion: estimateTokens(response), Estimated: true}
+}
+
+// formatTokens reads like "950", "3.4k" or "12k".
+func formatTokens(n int) string {
+	switch {
+	case n < 1000:
+		return fmt.Sprint(n)
+	case n < 10000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	}
+	return fmt.Sprintf("%dk", n/1000)
+}
+
+// Stats summarises a chat from what its messages store.
+type Stats struct {
+	Messages  map[Role]int
+	Chars     int
+	First     int64
+	Last      int64
+	Responses int   // bot messages with a measured latency
+	Latency   int64 // total of those, in milliseconds
+	Usage     Usage // of all exchanges
+}
+
+func sessionStats(messages []Message) Stats {
+	stats := Stats{Messages: map[Role]int{}}
+	for _, message := range messages {
+		stats.Messages[message.Role]++
+		stats.Chars += len([]rune(message.Text))
+		if message.Time != 0 {
+			if stats.First == 0 {
+				stats.First = message.Time
+			}
+			stats.Last = message.Time
+		}
+		if message.Role == RoleBot && message.Latency > 0 {
+			stats.Responses++
+			stats.Latency += message.Latency
+		}
+		stats.Usage = stats.Usage.add(message.Usage)
+	}
+	return stats
+}
+
+// Tokens is a rough estimate, see estimateTokens.
+func (s Stats) Tokens() int {
+	return (s.Chars + 3) / 4
+}
+
+func (s Stats) AverageLatency() time.Duration {
+	if s.Responses == 0 {
+		return 0
+	}
+	return time.Duration(s.Latency/int64(s.Responses)) * time.Millisecond
+}
+
+func (m model) openStats() (model, tea.Cmd) {
+	m.mode = modeStats
+	m.textarea.Blur()
+	return m, nil
+}
+
+func (m model) statsView() string {
+	stats := m.stats
+	when := func(unix int64) string {
+		if unix == 0 {
+			return "-"
+		}
+		return time.Unix(unix, 0).Format("2006-01-02 15:04:05")
+	}
+	usage := "-"
+	if total := stats.Usage.Total(); total > 0 {
+		usage = fmt.Sprintf("%d (%d sent, %d received)", total, stats.Usage.Prompt, stats.Usage.Completion)
+		if stats.Usage.Estimated {
+			usage += ", partly estimated"
+		}
+	}
+	cost := "-"
+	if stats.Usage.Cost > 0 {
+		cost = formatCost(stats.Usage.Cost)
+	}
+	latency := "-"
+	if stats.Responses > 0 {
+		latency = fmt.Sprintf("%s over %d responses", stats.AverageLatency().Round(time.Millisecond), stats.Responses)
+	}
+
+	rows := [][2]string{
+		{"Session", m.sessionTitle()},
+		{"Backend", backendName(m.meta.Provider, m.meta.Model)},
+		{"User messages", fmt.Sprint(stats.Messages[RoleUser])},
+		{"Bot messages", fmt.Sprint(stats.Messages[RoleBot])},
+		{"System messages", fmt.Sprint(stats.Messages[RoleSystem])},
+		{"Characters", fmt.Sprint(stats.Chars)},
+		{"Tokens (approx.)", fmt.Sprint(stats.Tokens())},
+		{"Tokens used", usage},
+		{"Estimated cost", cost},
+		{"First activity", when(stats.First)},
+		{"Last activity", when(stats.Last)},
+		{"Average latency", latency},
+	}
+
+	lines := []string{menuTitleStyle.Render("Session stats"), menuDetailStyle.Render("esc close"), ""}
+	for _, row := range rows {
+		lines = append(lines, menuDetailStyle.Render(fmt.Sprintf("%-18s", row[0]))+row[1])
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+var (
+	statusStyle      = lipgloss.NewStyle()
+	statusDirtyStyle = lipgloss.NewStyle()
+	statusBusyStyle  = lipgloss.NewStyle()
+)
+
+// markSaved records that storage holds the chat as it is, so the status
+// bar can tell when there are changes that have not been saved.
+func (m *model) markSaved() {
+	m.unsaved = false
+	m.stats = sessionStats(m.messages)
+}
+
+// changed records an edit to the messages or meta of the chat; whatever
+// changes them calls it.
+func (m *model) changed() {
+	m.unsaved = true
+	m.stats = sessionStats(m.messages)
+}
+
+func (m model) dirty() bool {
+	return m.unsaved
+}
+
+// statusBar is the line between the transcript and the input box.
+func (m model) statusBar(width int) string {
+	session := "new chat"
+	if m.currentId != 0 {
+		session = fmt.Sprintf("#%d", m.currentId)
+	}
+	if m.compact {
+		// there is no border to put the title on
+		session = m.sessionTitle()
+	}
+	parts := []string{
+		statusStyle.Render(session),
+		statusStyle.Render(backendName(m.meta.Provider, m.meta.Model)),
+	}
+	if usage := m.stats.Usage; usage.Total() > 0 {
+		tokens := formatTokens(usage.Total()) + " tokens"
+		if usage.Estimated {
+			tokens = "~" + tokens
+		}
+		parts = append(parts, statusStyle.Render(tokens))
+		if usage.Cost > 0 {
+			parts = append(parts, statusStyle.Render(formatCost(usage.Cost)))
+		}
+	}
+
+	switch {
+	case m.dirty():
+		parts = append(parts, statusDirtyStyle.Render("● unsaved"))
+	case m.currentId != 0:
+		parts = append(parts, statusStyle.Render("saved"))
+	}
+	if m.editing > 0 {
+		parts = append(parts, statusDirtyStyle.Render(fmt.Sprintf("editing message %d", m.editing)))
+	}
+	if m.cliLoading {
+		parts = append(parts, m.loadingStatus())
+	}
+	if len(m.queue) > 0 {
+		parts = append(parts, statusBusyStyle.Render(m.queueStatus()))
+	}
+	if m.storage.syncing {
+		parts = append(parts, statusBusyStyle.Render("syncing…"))
+	}
+
+	if position := m.scrollPosition(); m.compact && position != "" {
+		parts = append(parts, statusStyle.Render(position))
+	}
+	if (m.compact || m.inline) && m.toast != "" {
+		parts = append(parts, toastStyle.Render(m.toast))
+	}
+	return ansi.Truncate(strings.Join(parts, statusStyle.Render(" · ")), width, "…")
+}
@@ -2,51 +2,260 @@ package main
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 )
 
 const (
-	FOLDER_NAME          = "chat"
-	DB_NAME              = "chat.db"
-	MAXIMUM_MESSAGE_SIZE = 4096
-	HEADER_SIZE          = 16 // 4 + 4 + 4 + 4 = 16 bytes
-	CONTENT_SIZE         = 22 + MAXIMUM_MESSAGE_SIZE
+	FOLDER_NAME         = "chat"
+	DB_NAME             = "chat.db"
+	VERSION             = 3
+	HEADER_SIZE         = 16 // 4 + 4 + 4 + 4 = 16 bytes
+	RECORD_HEADER_SIZE  = 32 // 4 + 8 + 8 + 8 + 4 = 32 bytes
+	MAXIMUM_RECORD_SIZE = 16 << 20
+
+	// KEEP_VERSIONS is how many versions of a record are kept. The flat
+	// file lets them pile up to twice as many before it compacts; the
+	// other backends drop the oldest as they go.
+	KEEP_VERSIONS = 10
 )
 
 type Header struct {
 	Magic   [4]byte // Identifier for CHAT ("CHAT")
 	Version uint32
-	Record  uint32
-	Count   uint32
+	Record  uint32 // records appended to the log, including old versions
+	Count   uint32 // highest id handed out
 }
 
 type Content struct {
 	Id        uint32 // 4 bytes
 	CreatedAt int64  // 8 bytes
 	UpdatedAt int64  // 8 bytes
-	Length    uint16 // 2 bytes
-	Content   [MAXIMUM_MESSAGE_SIZE]byte
+	DeletedAt int64  // 8 bytes, 0 unless the record is in the trash
+	Length    uint32 // 4 bytes
+	Content   []byte
 }
 
+var (
+	ErrNotFound       = errors.New("record not found")
+	errNotInitialized = errors.New("storage is not initialized")
+)
+
+// Storage is an append-only log of records after a 16 byte header. Writing
+// an id again appends a new version; the last one wins on read. Sync uses
+// it from a goroutine of its own, so mu guards everything below it.
 type Storage struct {
 	stdOut chan string
+	mu     sync.Mutex
 	header Header
+	path   string             // defaults to FOLDER_NAME/DB_NAME
+	index  map[uint32][]int64 // id -> record offsets, oldest first
+	end    int64              // where the next record is appended
 }
 
 type Store interface {
 	Check() error
 	Initialize() error
-	Store(id uint32, content Content) uint32
-	Get(id uint32) string
-	GetIds() []uint32
-	GetOffset(id uint32) uint32
+	Store(id uint32, content Content) (uint32, error)
+	Update(id uint32, content Content) error
+	Get(id uint32) (Content, error)
+	GetVersions(id uint32) ([]Content, error)
+	GetIds() ([]uint32, error)
+	List() ([]Content, error)
+	Delete(id uint32) error
+	Restore(id uint32) error
+	ListTrash() ([]Content, error)
+	PurgeTrash(grace time.Duration) (int, error)
+}
+
+func openStore(cfg StorageConfig, stdOut chan string) Store {
+	switch cfg.Backend {
+	case "s3":
+		return NewS3Store(stdOut, cfg.S3Bucket, cfg.S3Prefix)
+	case "bolt":
+		return NewBoltStore(stdOut, cfg.BoltPath)
+	default:
+		return &Storage{stdOut: stdOut}
+	}
+}
+
+// copyRecords writes every record of src into dst under the same id.
+func copyRecords(dst, src Store) (int, error) {
+	ids, err := src.GetIds()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, id := range ids {
+		content, err := src.Get(id)
+		if err != nil {
+			return i, err
+		}
+		if _, err := dst.Store(id, content); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+// updateRecord overwrites an existing record, keeping its CreatedAt and
+// bumping UpdatedAt. Backends keep the previous version around.
+func updateRecord(s Store, id uint32, content Content) error {
+	current, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	content.CreatedAt = current.CreatedAt
+	content.UpdatedAt = time.Now().Unix()
+	_, err = s.Store(id, content)
+	return err
+}
+
+// trashRecord and restoreRecord bump UpdatedAt so the change wins when the
+// record is synced.
+func trashRecord(s Store, id uint32) error {
+	content, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	content.DeletedAt = time.Now().Unix()
+	content.UpdatedAt = content.DeletedAt
+	_, err = s.Store(id, content)
+	return err
+}
+
+func restoreRecord(s Store, id uint32) error {
+	content, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if content.DeletedAt == 0 {
+		return nil
+	}
+
+	content.DeletedAt = 0
+	content.UpdatedAt = time.Now().Unix()
+	_, err = s.Store(id, content)
+	return err
+}
+
+// listRecords returns every record that is not in the trash.
+func listRecords(s Store) ([]Content, error) {
+	ids, err := s.GetIds()
+	if err != nil {
+		return nil, err
+	}
+
+	records := []Content{}
+	for _, id := range ids {
+		content, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if content.DeletedAt == 0 {
+			records = append(records, content)
+		}
+	}
+	return records, nil
+}
+
+func listTrash(s Store) ([]Content, error) {
+	ids, err := s.GetIds()
+	if err != nil {
+		return nil, err
+	}
+
+	trash := []Content{}
+	for _, id := range ids {
+		content, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if content.DeletedAt != 0 {
+			trash = append(trash, content)
+		}
+	}
+	return trash, nil
+}
+
+// expiredTrash lists the trashed records deleted more than grace ago.
+func expiredTrash(s Store, grace time.Duration) ([]uint32, error) {
+	trash, err := s.ListTrash()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-grace).Unix()
+	ids := []uint32{}
+	for _, content := range trash {
+		if content.DeletedAt <= cutoff {
+			ids = append(ids, content.Id)
+		}
+	}
+	return ids, nil
+}
+
+// checkRecordSize refuses content that could not be read back.
+func checkRecordSize(content Content) error {
+	if len(content.Content) > MAXIMUM_RECORD_SIZE {
+		return fmt.Errorf("chat is %d bytes; a record holds at most %d", len(content.Content), MAXIMUM_RECORD_SIZE)
+	}
+	return nil
 }
 
-func (s *Storage) GetOffset(id uint32) uint32 {
-	return HEADER_SIZE + (id * CONTENT_SIZE)
+func encodeContent(id uint32, content Content) []byte {
+	buffer := make([]byte, RECORD_HEADER_SIZE+len(content.Content))
+	binary.BigEndian.PutUint32(buffer[:4], id)
+	binary.BigEndian.PutUint64(buffer[4:12], uint64(content.CreatedAt))
+	binary.BigEndian.PutUint64(buffer[12:20], uint64(content.UpdatedAt))
+	binary.BigEndian.PutUint64(buffer[20:28], uint64(content.DeletedAt))
+	binary.BigEndian.PutUint32(buffer[28:32], uint32(len(content.Content)))
+	copy(buffer[RECORD_HEADER_SIZE:], content.Content)
+	return buffer
+}
+
+func decodeRecordHeader(buffer []byte) (Content, error) {
+	var content Content
+	content.Id = binary.BigEndian.Uint32(buffer[:4])
+	content.CreatedAt = int64(binary.BigEndian.Uint64(buffer[4:12]))
+	content.UpdatedAt = int64(binary.BigEndian.Uint64(buffer[12:20]))
+	content.DeletedAt = int64(binary.BigEndian.Uint64(buffer[20:28]))
+	content.Length = binary.BigEndian.Uint32(buffer[28:32])
+	if content.Length > MAXIMUM_RECORD_SIZE {
+		return Content{}, fmt.Errorf("record %d has invalid length %d", content.Id, content.Length)
+	}
+	return content, nil
+}
+
+func decodeContent(buffer []byte) (Content, error) {
+	if len(buffer) < RECORD_HEADER_SIZE {
+		return Content{}, fmt.Errorf("record too short: %d bytes", len(buffer))
+	}
+
+	content, err := decodeRecordHeader(buffer)
+	if err != nil {
+		return Content{}, err
+	}
+	if len(buffer) < RECORD_HEADER_SIZE+int(content.Length) {
+		return Content{}, fmt.Errorf("record %d is truncated", content.Id)
+	}
+	content.Content = append([]byte(nil), buffer[RECORD_HEADER_SIZE:RECORD_HEADER_SIZE+int(content.Length)]...)
+	return content, nil
+}
+
+func (s *Storage) dbPath() string {
+	if s.path == "" {
+		return filepath.Join(FOLDER_NAME, DB_NAME)
+	}
+	return s.path
 }
 
 func (h *Header) GenerateId() uint32 {
@@ -54,7 +263,7 @@ func (h *Header) GenerateId() uint32 {
 }
 
 func (s *Storage) Check() error {
-	file := filepath.Join(FOLDER_NAME, DB_NAME)
+	file := s.dbPath()
 	if _, error := os.OpenFile(file, os.O_RDONLY, 0644); error != nil {
 		return error
 	}
@@ -62,8 +271,9 @@ func (s *Storage) Check() error {
 }
 
 func (s *Storage) Initialize() error {
-	if err := os.MkdirAll(FOLDER_NAME, 0755); err != nil {
-		fmt.Println("Error creating folder: ", err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(s.dbPath()), 0755); err != nil {
 		return err
 	}
 
@@ -71,10 +281,25 @@ func (s *Storage) Initialize() error {
 		s.stdOut <- "Creating database..."
 	}()
 
-	path := filepath.Join(FOLDER_NAME, DB_NAME)
+	path := s.dbPath()
 	file, error := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
 	if os.IsExist(error) {
-		s.loadHeader()
+		if err := s.loadHeader(); err != nil {
+			return err
+		}
+		if s.header.Version < VERSION {
+			if err := s.migrate(); err != nil {
+				return fmt.Errorf("migrating %s: %w", path, err)
+			}
+		}
+		if err := s.loadIndex(); err != nil {
+			return err
+		}
+		if s.overgrown() {
+			if err := s.compact(); err != nil {
+				return fmt.Errorf("compacting %s: %w", path, err)
+			}
+		}
 		go func() {
 			s.stdOut <- "Database already exists"
 		}()
@@ -82,7 +307,6 @@ func (s *Storage) Initialize() error {
 	}
 
 	if error != nil {
-		fmt.Println("Error initializing storage:", error)
 		return error
 	}
 
@@ -90,11 +314,13 @@ func (s *Storage) Initialize() error {
 
 	s.header = Header{
 		Magic:   [4]byte{'C', 'H', 'A', 'T'},
-		Version: 1,
+		Version: VERSION,
 		Record:  0,
 		Count:   0,
 	}
 	s.saveHeader()
+	s.index = map[uint32][]int64{}
+	s.end = HEADER_SIZE
 
 	go func() {
 		s.stdOut <- "Database created successfully"
@@ -104,7 +330,7 @@ func (s *Storage) Initialize() error {
 }
 
 func (s *Storage) loadHeader() error {
-	path := filepath.Join(FOLDER_NAME, DB_NAME)
+	path := s.dbPath()
 	file, err := os.OpenFile(path, os.O_RDONLY, 0644)
 	if err != nil {
 		return err
@@ -125,7 +351,7 @@ func (s *Storage) loadHeader() error {
 }
 
 func (s *Storage) saveHeader() error {
-	path := filepath.Join(FOLDER_NAME, DB_NAME)
+	path := s.dbPath()
 	file, err := os.OpenFile(path, os.O_WRONLY, 0644)
 	if err != nil {
 		return err
@@ -146,37 +372,151 @@ func (s *Storage) saveHeader() error {
 	return nil
 }
 
-func (s *Storage) Store(id uint32, content Content) (uint32, error) {
-	if id == 0 {
-		id = s.header.GenerateId()
+// loadIndex scans the log and remembers where every version of every id
+// lives. A torn record at the tail (e.g. a crash mid-write) is ignored and
+// overwritten by the next append; a record that cannot be read anywhere
+// else is an error, as appending there would overwrite what follows.
+func (s *Storage) loadIndex() error {
+	file, err := os.OpenFile(s.dbPath(), os.O_RDONLY, 0644)
+	if err != nil {
+		return err
 	}
-	offset := s.GetOffset(id)
+	defer file.Close()
 
-	// Write content to file
-	path := filepath.Join(FOLDER_NAME, DB_NAME)
-	file, error := os.OpenFile(path, os.O_WRONLY, 0644)
-	if error != nil {
-		fmt.Println("Error opening file:", error)
-		return 0, error
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	s.index = map[uint32][]int64{}
+	offset := int64(HEADER_SIZE)
+	buf := make([]byte, RECORD_HEADER_SIZE)
+	for offset+RECORD_HEADER_SIZE <= info.Size() {
+		if _, err := file.ReadAt(buf, offset); err != nil {
+			return err
+		}
+		content, err := decodeRecordHeader(buf)
+		if err != nil {
+			return fmt.Errorf("%s is corrupt at offset %d: %w", s.dbPath(), offset, err)
+		}
+		next := offset + RECORD_HEADER_SIZE + int64(content.Length)
+		if next > info.Size() {
+			break
+		}
+
+		s.index[content.Id] = append(s.index[content.Id], offset)
+		s.header.Count = max(s.header.Count, content.Id)
+		offset = next
+	}
+	s.end = offset
+
+	return nil
+}
+
+func (s *Storage) readRecord(file *os.File, offset int64) (Content, error) {
+	buf := make([]byte, RECORD_HEADER_SIZE)
+	if _, err := file.ReadAt(buf, offset); err != nil {
+		return Content{}, err
+	}
+	content, err := decodeRecordHeader(buf)
+	if err != nil {
+		return Content{}, err
+	}
+
+	content.Content = make([]byte, content.Length)
+	if _, err := file.ReadAt(content.Content, offset+RECORD_HEADER_SIZE); err != nil {
+		return Content{}, err
+	}
+	return content, nil
+}
+
+func (s *Storage) appendRecord(id uint32, content Content) error {
+	if s.index == nil {
+		return errNotInitialized
+	}
+	file, err := os.OpenFile(s.dbPath(), os.O_WRONLY, 0644)
+	if err != nil {
+		return err
 	}
 	defer file.Close()
 
-	buffer := make([]byte, CONTENT_SIZE)
-	binary.BigEndian.PutUint32(buffer[:4], id)
-	binary.BigEndian.PutUint64(buffer[4:12], uint64(content.CreatedAt))
-	binary.BigEndian.PutUint64(buffer[12:20], uint64(content.UpdatedAt))
-	binary.BigEndian.PutUint16(buffer[20:22], content.Length)
-	copy(buffer[22:], content.Content[:content.Length])
+	buffer := encodeContent(id, content)
+	if _, err := file.WriteAt(buffer, s.end); err != nil {
+		return err
+	}
+
+	s.index[id] = append(s.index[id], s.end)
+	s.end += int64(len(buffer))
+	s.header.Record++
+	// ids written explicitly (e.g. by sync) may skip ahead of Count
+	s.header.Count = max(s.header.Count, id)
+	return s.saveHeader()
+}
 
-	if _, error := file.WriteAt(buffer, int64(offset)); error != nil {
-		fmt.Println("Error writing to file:", error)
-		return 0, error
+// rewriteLog replaces the file with a fresh log holding only records, via
+// a temporary file so a crash never leaves a half-written database.
+func (s *Storage) rewriteLog(records []Content) error {
+	path := s.dbPath()
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
 	}
 
+	s.header.Magic = [4]byte{'C', 'H', 'A', 'T'}
+	s.header.Version = VERSION
+	s.header.Record = uint32(len(records))
+
+	buf := make([]byte, HEADER_SIZE)
+	copy(buf[:4], s.header.Magic[:])
+	binary.BigEndian.PutUint32(buf[4:8], s.header.Version)
+	binary.BigEndian.PutUint32(buf[8:12], s.header.Record)
+	binary.BigEndian.PutUint32(buf[12:16], s.header.Count)
+	if _, err := file.Write(buf); err != nil {
+		file.Close()
+		return err
+	}
+	for _, record := range records {
+		if _, err := file.Write(encodeContent(record.Id, record)); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return s.loadIndex()
+}
+
+func (s *Storage) Store(id uint32, content Content) (uint32, error) {
+	if err := checkRecordSize(content); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if id == 0 {
-		s.header.Count++
-		s.header.Record++
-		s.saveHeader()
+		id = s.header.GenerateId()
+	}
+
+	if err := s.appendRecord(id, content); err != nil {
+		return 0, err
+	}
+	if len(s.index[id]) > 2*KEEP_VERSIONS {
+		if err := s.compact(); err != nil {
+			// the record is stored; the log is only longer than it needs to be
+			go func() {
+				s.stdOut <- "Could not compact the database: " + err.Error()
+			}()
+		}
 	}
 
 	go func() {
@@ -186,6 +526,144 @@ func (s *Storage) Store(id uint32, content Content) (uint32, error) {
 	return id, nil
 }
 
-func (s *Storage) Get(id int64) string {
-	return ""
+func (s *Storage) Update(id uint32, content Content) error {
+	return updateRecord(s, id, content)
+}
+
+func (s *Storage) Get(id uint32) (Content, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offsets := s.index[id]
+	if len(offsets) == 0 {
+		return Content{}, ErrNotFound
+	}
+
+	file, err := os.OpenFile(s.dbPath(), os.O_RDONLY, 0644)
+	if err != nil {
+		return Content{}, err
+	}
+	defer file.Close()
+
+	return s.readRecord(file, offsets[len(offsets)-1])
+}
+
+// GetVersions returns every stored version of id, oldest first. The last
+// one is what Get returns.
+func (s *Storage) GetVersions(id uint32) ([]Content, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offsets := s.index[id]
+	if len(offsets) == 0 {
+		return nil, ErrNotFound
+	}
+
+	file, err := os.OpenFile(s.dbPath(), os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	versions := make([]Content, 0, len(offsets))
+	for _, offset := range offsets {
+		content, err := s.readRecord(file, offset)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, content)
+	}
+	return versions, nil
+}
+
+func (s *Storage) Delete(id uint32) error {
+	return trashRecord(s, id)
+}
+
+func (s *Storage) Restore(id uint32) error {
+	return restoreRecord(s, id)
+}
+
+func (s *Storage) List() ([]Content, error) {
+	return listRecords(s)
+}
+
+func (s *Storage) ListTrash() ([]Content, error) {
+	return listTrash(s)
+}
+
+// PurgeTrash compacts the log: every version of records that have been in
+// the trash longer than grace is dropped, everything else is kept.
+func (s *Storage) PurgeTrash(grace time.Duration) (int, error) {
+	expired, err := expiredTrash(s, grace)
+	if err != nil || len(expired) == 0 {
+		return 0, err
+	}
+
+	purge := map[uint32]bool{}
+	for _, id := range expired {
+		purge[id] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.rewriteKeeping(func(offset int64, id uint32) bool { return !purge[id] }); err != nil {
+		return 0, err
+	}
+
+	go func() {
+		s.stdOut <- fmt.Sprintf("Purged %d chats from the trash", len(expired))
+	}()
+	return len(expired), nil
+}
+
+// overgrown says whether a record has piled up enough versions to compact
+// the log.
+func (s *Storage) overgrown() bool {
+	for _, offsets := range s.index {
+		if len(offsets) > 2*KEEP_VERSIONS {
+			return true
+		}
+	}
+	return false
+}
+
+// compact drops all but the last KEEP_VERSIONS versions of every record.
+func (s *Storage) compact() error {
+	return s.rewriteKeeping(func(offset int64, id uint32) bool {
+		offsets := s.index[id]
+		return offset >= offsets[max(len(offsets)-KEEP_VERSIONS, 0)]
+	})
+}
+
+// rewriteKeeping rewrites the log with the records keep says yes to, in
+// log order so versions stay oldest first.
+func (s *Storage) rewriteKeeping(keep func(offset int64, id uint32) bool) error {
+	file, err := os.OpenFile(s.dbPath(), os.O_RDONLY, 0644)
+	if err != nil {
+		return err
+	}
+	var records []Content
+	for offset := int64(HEADER_SIZE); offset < s.end; {
+		record, err := s.readRecord(file, offset)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if keep(offset, record.Id) {
+			records = append(records, record)
+		}
+		offset += RECORD_HEADER_SIZE + int64(record.Length)
+	}
+	file.Close()
+	return s.rewriteLog(records)
+}
+
+func (s *Storage) GetIds() ([]uint32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]uint32, 0, len(s.index))
+	for id := range s.index {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
 }
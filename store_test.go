@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestStorage is an initialized Storage in a directory of the test's own.
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	s := &Storage{stdOut: make(chan string, 100), path: filepath.Join(t.TempDir(), DB_NAME)}
+	if err := s.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestContentEncoding(t *testing.T) {
+	tests := []Content{
+		{CreatedAt: 1, UpdatedAt: 2},
+		{CreatedAt: 1700000000, UpdatedAt: 1700000100, DeletedAt: 1700000200, Content: []byte(`{"messages":[]}`)},
+		{CreatedAt: -1, UpdatedAt: 0, Content: bytes.Repeat([]byte{0, 0xff}, 1000)},
+	}
+	for _, content := range tests {
+		buffer := encodeContent(7, content)
+		if len(buffer) != RECORD_HEADER_SIZE+len(content.Content) {
+			t.Errorf("encoded %d bytes of content into %d bytes", len(content.Content), len(buffer))
+		}
+		decoded, err := decodeContent(buffer)
+		if err != nil {
+			t.Errorf("decodeContent: %v", err)
+			continue
+		}
+		if decoded.Id != 7 || decoded.CreatedAt != content.CreatedAt || decoded.UpdatedAt != content.UpdatedAt ||
+			decoded.DeletedAt != content.DeletedAt || int(decoded.Length) != len(content.Content) || !bytes.Equal(decoded.Content, content.Content) {
+			t.Errorf("decoded %+v, want %+v with id 7", decoded, content)
+		}
+	}
+}
+
+func TestContentDecodingErrors(t *testing.T) {
+	valid := encodeContent(3, Content{Content: []byte("hello")})
+	oversize := bytes.Clone(valid)
+	binary.BigEndian.PutUint32(oversize[28:32], MAXIMUM_RECORD_SIZE+1)
+
+	tests := map[string][]byte{
+		"empty":           nil,
+		"short header":    valid[:RECORD_HEADER_SIZE-1],
+		"truncated":       valid[:len(valid)-1],
+		"oversize length": oversize,
+	}
+	for name, buffer := range tests {
+		if _, err := decodeContent(buffer); err == nil {
+			t.Errorf("%s: decodeContent did not fail", name)
+		}
+	}
+}
+
+func TestStorageVersions(t *testing.T) {
+	s := newTestStorage(t)
+	id, err := s.Store(0, Content{CreatedAt: 10, UpdatedAt: 10, Content: []byte("one")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Update(id, Content{Content: []byte("two")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Update(id+1, Content{Content: []byte("nobody")}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("updating a missing record: %v, want ErrNotFound", err)
+	}
+
+	// reopening reads the versions back from the log
+	reopened := &Storage{stdOut: make(chan string, 100), path: s.path}
+	if err := reopened.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []*Storage{s, reopened} {
+		versions, err := s.GetVersions(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(versions) != 2 || string(versions[0].Content) != "one" || string(versions[1].Content) != "two" {
+			t.Errorf("versions are %v, want one and two", versions)
+		}
+		if versions[1].CreatedAt != 10 {
+			t.Errorf("an update changed CreatedAt to %d", versions[1].CreatedAt)
+		}
+		latest, err := s.Get(id)
+		if err != nil || string(latest.Content) != "two" {
+			t.Errorf("Get returns %q, %v; want the last version", latest.Content, err)
+		}
+	}
+
+	if next, err := reopened.Store(0, Content{Content: []byte("new")}); err != nil || next != id+1 {
+		t.Errorf("the next id is %d, %v; want %d", next, err, id+1)
+	}
+}
+
+func TestStorageCompacts(t *testing.T) {
+	s := newTestStorage(t)
+	id, err := s.Store(0, Content{Content: []byte("0")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 * KEEP_VERSIONS {
+		if err := s.Update(id, Content{Content: []byte{byte('a' + i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	versions, err := s.GetVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) < KEEP_VERSIONS || len(versions) > 2*KEEP_VERSIONS {
+		t.Errorf("%d versions kept, want between %d and %d", len(versions), KEEP_VERSIONS, 2*KEEP_VERSIONS)
+	}
+	if last := versions[len(versions)-1].Content; last[0] != byte('a'+3*KEEP_VERSIONS-1) {
+		t.Errorf("the last version is %q", last)
+	}
+}
+
+func TestStorageTornTail(t *testing.T) {
+	s := newTestStorage(t)
+	if _, err := s.Store(0, Content{Content: []byte("kept")}); err != nil {
+		t.Fatal(err)
+	}
+	torn := encodeContent(2, Content{Content: []byte("never finished")})
+	appendFile(t, s.path, torn[:len(torn)-3])
+
+	reopened := &Storage{stdOut: make(chan string, 100), path: s.path}
+	if err := reopened.Initialize(); err != nil {
+		t.Fatalf("a torn last record is not tolerated: %v", err)
+	}
+	if ids, _ := reopened.GetIds(); len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("ids after a torn write are %v, want [1]", ids)
+	}
+
+	corrupt := encodeContent(3, Content{})
+	binary.BigEndian.PutUint32(corrupt[28:32], MAXIMUM_RECORD_SIZE+1)
+	s = newTestStorage(t)
+	appendFile(t, s.path, corrupt)
+	if err := (&Storage{stdOut: make(chan string, 100), path: s.path}).Initialize(); err == nil {
+		t.Error("a corrupt record header is not reported")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tests := map[int]func(t *testing.T, path string){
+		1: writeV1,
+		2: writeV2,
+	}
+	for version, write := range tests {
+		path := filepath.Join(t.TempDir(), DB_NAME)
+		write(t, path)
+
+		s := &Storage{stdOut: make(chan string, 100), path: path}
+		if err := s.Initialize(); err != nil {
+			t.Fatalf("v%d: %v", version, err)
+		}
+		if s.header.Version != VERSION {
+			t.Errorf("v%d: migrated to version %d, want %d", version, s.header.Version, VERSION)
+		}
+		if _, err := os.Stat(fmt.Sprintf("%s.v%d", path, version)); err != nil {
+			t.Errorf("v%d: no backup of the original: %v", version, err)
+		}
+		for id, text := range map[uint32]string{1: "first", 3: "third"} {
+			content, err := s.Get(id)
+			if err != nil {
+				t.Errorf("v%d: record %d: %v", version, id, err)
+				continue
+			}
+			if string(content.Content) != text || content.CreatedAt != int64(id)*100 || content.UpdatedAt != int64(id)*100+1 || content.DeletedAt != 0 {
+				t.Errorf("v%d: record %d is %+v", version, id, content)
+			}
+		}
+		if _, err := s.Get(2); !errors.Is(err, ErrNotFound) {
+			t.Errorf("v%d: record 2 was never stored, Get says %v", version, err)
+		}
+	}
+}
+
+func testHeader(version, count uint32) []byte {
+	buffer := make([]byte, HEADER_SIZE)
+	copy(buffer, "CHAT")
+	binary.BigEndian.PutUint32(buffer[4:8], version)
+	binary.BigEndian.PutUint32(buffer[8:12], count)
+	binary.BigEndian.PutUint32(buffer[12:16], count)
+	return buffer
+}
+
+// writeV1 writes records 1 and 3 in their fixed-size slots.
+func writeV1(t *testing.T, path string) {
+	data := testHeader(1, 3)
+	data = append(data, make([]byte, 4*CONTENT_SIZE)...)
+	for id, text := range map[uint32]string{1: "first", 3: "third"} {
+		slot := data[HEADER_SIZE+int(id)*CONTENT_SIZE:]
+		binary.BigEndian.PutUint32(slot[:4], id)
+		binary.BigEndian.PutUint64(slot[4:12], uint64(id)*100)
+		binary.BigEndian.PutUint64(slot[12:20], uint64(id)*100+1)
+		binary.BigEndian.PutUint16(slot[20:22], uint16(len(text)))
+		copy(slot[22:], text)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeV2 writes records 1 and 3 to a log, 1 twice.
+func writeV2(t *testing.T, path string) {
+	data := testHeader(2, 3)
+	for _, record := range []struct {
+		id   uint32
+		text string
+	}{{1, "old"}, {3, "third"}, {1, "first"}} {
+		header := make([]byte, V2_RECORD_HEADER_SIZE)
+		binary.BigEndian.PutUint32(header[:4], record.id)
+		binary.BigEndian.PutUint64(header[4:12], uint64(record.id)*100)
+		binary.BigEndian.PutUint64(header[12:20], uint64(record.id)*100+1)
+		binary.BigEndian.PutUint32(header[20:24], uint32(len(record.text)))
+		data = append(append(data, header...), record.text...)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
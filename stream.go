@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Responses are shown as they arrive: runChatCommand sends the backend's
+// output on a channel while it runs and the final cliResponseMsg replaces
+// what was streamed with the complete text.
+
+// streamMsg is a piece of the pending response.
+type streamMsg struct {
+	stream chan string
+	text   string
+}
+
+func waitForStream(stream chan string) tea.Cmd {
+	return func() tea.Msg {
+		text, ok := <-stream
+		if !ok {
+			return nil
+		}
+		return streamMsg{stream: stream, text: text}
+	}
+}
+
+// appendPartial shows more of the response, following it down unless the
+// transcript was scrolled up to read something else.
+func (m *model) appendPartial(text string) {
+	following := m.viewport.AtBottom()
+	m.partial += text
+	m.heardAt = time.Now()
+	m.refreshTranscript()
+	if following {
+		m.viewport.GotoBottom()
+	} else {
+		m.newBelow = true
+	}
+}
+
+// scrollPosition reads like "57%" while the transcript is longer than the
+// viewport, with "↓ new" when a response came in below the view.
+func (m model) scrollPosition() string {
+	if m.viewport.TotalLineCount() <= m.viewport.Height {
+		return ""
+	}
+	position := fmt.Sprintf("%d%%", int(m.viewport.ScrollPercent()*100))
+	if m.newBelow && !m.viewport.AtBottom() {
+		position += " · ↓ new"
+	}
+	return position
+}
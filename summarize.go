@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type summaryMsg struct {
+	text     string
+	count    int // messages the summary covers, from the start of the chat
+	collapse bool
+	err      error
+	request  int
+}
+
+// summarize asks the backend for a summary of the chat so far. It is added
+// as a pinned system message, which unlike other system messages is sent
+// as context; with collapse the summarized messages are folded away in the
+// viewport and no longer sent.
+func (m model) summarize(collapse bool) (model, tea.Cmd) {
+	var b strings.Builder
+	b.WriteString("Summarize the conversation below in a short paragraph, keeping facts, decisions and open questions.\n\n")
+	count := 0
+	for _, message := range m.messages {
+		if message.Role != RoleSystem || message.Pinned {
+			b.WriteString(message.Role.Label() + ": " + message.Text + "\n")
+			count++
+		}
+	}
+	if count == 0 {
+		m.addMessage(RoleSystem, "Nothing to summarize yet")
+		return m, nil
+	}
+
+	provider, model, prompt, upto := m.meta.Provider, m.meta.Model, b.String(), len(m.messages)
+	ctx, loading := m.startLoading()
+	request := m.request
+	return m, tea.Batch(loading, func() tea.Msg {
+		out, err := askBackend(ctx, provider, model, prompt)
+		return summaryMsg{text: strings.TrimSpace(out), count: upto, collapse: collapse, err: err, request: request}
+	})
+}
+
+func (m *model) applySummary(msg summaryMsg) {
+	m.stopLoading()
+	if msg.err != nil {
+		m.addMessage(RoleSystem, "Error summarizing: "+msg.err.Error())
+		return
+	}
+
+	if msg.collapse {
+		for i := range min(msg.count, len(m.messages)) {
+			if !m.messages[i].Pinned {
+				m.messages[i].Collapsed = true
+			}
+		}
+		// the history the context summary counts into is shorter now
+		m.meta.Summary, m.meta.Summarized = "", 0
+	}
+	m.addMessage(RoleSystem, "Summary: "+msg.text)
+	m.messages[len(m.messages)-1].Pinned = true
+	m.changed()
+	m.refreshTranscript()
+	if m.autosave && !m.storage.syncing {
+		m.save()
+	}
+}
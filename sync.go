@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type SyncResult struct {
+	Pushed    int
+	Pulled    int
+	Relocated int
+	Moved     map[uint32]uint32 // local chats relocation moved, old id -> new id
+}
+
+// errSyncing is what writes get while a sync runs, see syncGuard.
+var errSyncing = errors.New("a sync is running; try again when it is done")
+
+// underlying is Store under a name that leaves syncGuard its Store method.
+type underlying = Store
+
+// syncGuard is the store the interface works with. A sync moves chats to
+// other ids while it runs, so until it is done the interface's writes are
+// refused and only the sync writes, to the store underneath.
+type syncGuard struct {
+	underlying
+	syncing bool // only touched by the interface's goroutine
+}
+
+func (g *syncGuard) Store(id uint32, content Content) (uint32, error) {
+	if g.syncing {
+		return 0, errSyncing
+	}
+	return g.underlying.Store(id, content)
+}
+
+func (g *syncGuard) Update(id uint32, content Content) error {
+	if g.syncing {
+		return errSyncing
+	}
+	return g.underlying.Update(id, content)
+}
+
+func (g *syncGuard) Delete(id uint32) error {
+	if g.syncing {
+		return errSyncing
+	}
+	return g.underlying.Delete(id)
+}
+
+func (g *syncGuard) Restore(id uint32) error {
+	if g.syncing {
+		return errSyncing
+	}
+	return g.underlying.Restore(id)
+}
+
+func (g *syncGuard) PurgeTrash(grace time.Duration) (int, error) {
+	if g.syncing {
+		return 0, errSyncing
+	}
+	return g.underlying.PurgeTrash(grace)
+}
+
+func (r SyncResult) String() string {
+	return fmt.Sprintf("pushed %d, pulled %d, relocated %d", r.Pushed, r.Pulled, r.Relocated)
+}
+
+// openRemoteStore resolves a sync target. "s3://bucket/prefix" talks to a
+// bucket; anything else is a path to a chat.db, or to the working directory
+// of another relay instance (its chat/chat.db is used).
+func openRemoteStore(spec string, stdOut chan string) (Store, error) {
+	if spec == "" {
+		return nil, errors.New("no sync remote configured (sync.remote or RELAY_SYNC_REMOTE)")
+	}
+
+	if rest, ok := strings.CutPrefix(spec, "s3://"); ok {
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		return NewS3Store(stdOut, bucket, strings.TrimSuffix(prefix, "/")), nil
+	}
+
+	path := spec
+	if info, err := os.Stat(spec); err == nil && info.IsDir() {
+		path = filepath.Join(spec, FOLDER_NAME, DB_NAME)
+	}
+	return &Storage{stdOut: stdOut, path: path}, nil
+}
+
+// Sync merges local and remote so both end up with the same records.
+// Records with the same id and CreatedAt are the same chat: the newer
+// UpdatedAt wins. Records sharing an id but created independently are
+// different chats: the older keeps the id and the younger moves to a
+// fresh id on both sides, which local hands out.
+func Sync(local, remote Store) (SyncResult, error) {
+	result := SyncResult{Moved: map[uint32]uint32{}}
+
+	localIds, err := local.GetIds()
+	if err != nil {
+		return result, err
+	}
+	remoteIds, err := remote.GetIds()
+	if err != nil {
+		return result, err
+	}
+
+	ids := map[uint32]bool{}
+	for _, id := range append(localIds, remoteIds...) {
+		ids[id] = true
+	}
+
+	type conflict struct {
+		id            uint32
+		local, remote Content
+	}
+	var conflicts []conflict
+
+	for id := range ids {
+		l, lerr := local.Get(id)
+		if lerr != nil && !errors.Is(lerr, ErrNotFound) {
+			return result, lerr
+		}
+		r, rerr := remote.Get(id)
+		if rerr != nil && !errors.Is(rerr, ErrNotFound) {
+			return result, rerr
+		}
+
+		switch {
+		case rerr != nil:
+			if _, err := remote.Store(id, l); err != nil {
+				return result, err
+			}
+			result.Pushed++
+		case lerr != nil:
+			if _, err := local.Store(id, r); err != nil {
+				return result, err
+			}
+			result.Pulled++
+		case l.CreatedAt != r.CreatedAt:
+			conflicts = append(conflicts, conflict{id, l, r})
+		case l.UpdatedAt > r.UpdatedAt:
+			if _, err := remote.Store(id, l); err != nil {
+				return result, err
+			}
+			result.Pushed++
+		case r.UpdatedAt > l.UpdatedAt:
+			if _, err := local.Store(id, r); err != nil {
+				return result, err
+			}
+			result.Pulled++
+		}
+	}
+
+	// every remote id is local by now, so the ids local hands out are new
+	// to both sides
+	for _, c := range conflicts {
+		// the side the younger chat moves off takes the older one in its
+		// place
+		localMoves := c.local.CreatedAt > c.remote.CreatedAt
+		older, younger, vacated := c.local, c.remote, remote
+		if localMoves {
+			older, younger, vacated = c.remote, c.local, local
+		}
+		moved, err := local.Store(0, younger)
+		if err != nil {
+			return result, err
+		}
+		if _, err := remote.Store(moved, younger); err != nil {
+			return result, err
+		}
+		if _, err := vacated.Store(c.id, older); err != nil {
+			return result, err
+		}
+		if localMoves {
+			result.Moved[c.id] = moved
+		}
+		result.Relocated++
+	}
+
+	return result, nil
+}
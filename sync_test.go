@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"testing"
+)
+
+// chat is a record as a sync test sets it up and expects it.
+type chat struct {
+	created, updated int64
+	text             string
+}
+
+func storeChats(t *testing.T, s Store, chats map[uint32]chat) {
+	t.Helper()
+	for id, c := range chats {
+		if _, err := s.Store(id, Content{CreatedAt: c.created, UpdatedAt: c.updated, Content: []byte(c.text)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func storedChats(t *testing.T, s Store) map[uint32]chat {
+	t.Helper()
+	ids, err := s.GetIds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chats := map[uint32]chat{}
+	for _, id := range ids {
+		content, err := s.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chats[id] = chat{content.CreatedAt, content.UpdatedAt, string(content.Content)}
+	}
+	return chats
+}
+
+func TestSync(t *testing.T) {
+	tests := []struct {
+		name           string
+		local, remote  map[uint32]chat
+		want           map[uint32]chat
+		pushed, pulled int
+		relocated      int
+		moved          map[uint32]uint32
+	}{
+		{
+			name:   "each side has chats the other lacks",
+			local:  map[uint32]chat{1: {10, 10, "a"}},
+			remote: map[uint32]chat{2: {20, 20, "b"}},
+			want:   map[uint32]chat{1: {10, 10, "a"}, 2: {20, 20, "b"}},
+			pushed: 1, pulled: 1,
+		},
+		{
+			name:   "the newer edit wins",
+			local:  map[uint32]chat{1: {10, 15, "local"}, 2: {20, 20, "old"}},
+			remote: map[uint32]chat{1: {10, 12, "remote"}, 2: {20, 25, "new"}},
+			want:   map[uint32]chat{1: {10, 15, "local"}, 2: {20, 25, "new"}},
+			pushed: 1, pulled: 1,
+		},
+		{
+			name:   "nothing to do",
+			local:  map[uint32]chat{1: {10, 10, "a"}},
+			remote: map[uint32]chat{1: {10, 10, "a"}},
+			want:   map[uint32]chat{1: {10, 10, "a"}},
+		},
+		{
+			name:      "the younger local chat moves",
+			local:     map[uint32]chat{1: {10, 10, "a"}, 2: {30, 30, "local"}},
+			remote:    map[uint32]chat{1: {10, 10, "a"}, 2: {20, 20, "remote"}},
+			want:      map[uint32]chat{1: {10, 10, "a"}, 2: {20, 20, "remote"}, 3: {30, 30, "local"}},
+			relocated: 1,
+			moved:     map[uint32]uint32{2: 3},
+		},
+		{
+			name:   "the younger remote chat moves",
+			local:  map[uint32]chat{1: {10, 10, "local"}},
+			remote: map[uint32]chat{1: {20, 20, "remote"}, 2: {30, 30, "b"}},
+			want:   map[uint32]chat{1: {10, 10, "local"}, 2: {30, 30, "b"}, 3: {20, 20, "remote"}},
+			pulled: 1, relocated: 1,
+		},
+	}
+	for _, tt := range tests {
+		local, remote := newTestStorage(t), newTestStorage(t)
+		storeChats(t, local, tt.local)
+		storeChats(t, remote, tt.remote)
+
+		result, err := Sync(local, remote)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if result.Pushed != tt.pushed || result.Pulled != tt.pulled || result.Relocated != tt.relocated {
+			t.Errorf("%s: %s", tt.name, result)
+		}
+		if !maps.Equal(result.Moved, tt.moved) {
+			t.Errorf("%s: moved %v, want %v", tt.name, result.Moved, tt.moved)
+		}
+		for side, s := range map[string]Store{"local": local, "remote": remote} {
+			if got := storedChats(t, s); !maps.Equal(got, tt.want) {
+				t.Errorf("%s: %s has %v, want %v", tt.name, side, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestSyncGuard(t *testing.T) {
+	guard := &syncGuard{underlying: newTestStorage(t)}
+	id, err := guard.Store(0, Content{Content: []byte("a")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	guard.syncing = true
+	if _, err := guard.Store(0, Content{}); !errors.Is(err, errSyncing) {
+		t.Errorf("Store while syncing: %v", err)
+	}
+	if err := guard.Update(id, Content{}); !errors.Is(err, errSyncing) {
+		t.Errorf("Update while syncing: %v", err)
+	}
+	if err := guard.Delete(id); !errors.Is(err, errSyncing) {
+		t.Errorf("Delete while syncing: %v", err)
+	}
+	if _, err := guard.Get(id); err != nil {
+		t.Errorf("Get while syncing: %v", err)
+	}
+	// the sync itself writes underneath
+	if _, err := guard.underlying.Store(0, Content{}); err != nil {
+		t.Errorf("the store underneath refuses: %v", err)
+	}
+}
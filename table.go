@@ -0,0 +1,98 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// tableDelimiter is the row under a Markdown table's header, like
+// "|---|:--:|--:|".
+var tableDelimiter = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?$`)
+
+var tableHeaderStyle = lipgloss.NewStyle().Bold(true)
+
+// renderTables draws the Markdown tables in text with box borders, fitted
+// to width when that is set. Tables inside code fences are left alone.
+func renderTables(text string, width int) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+
+	fenced := false
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, "```") {
+			fenced = !fenced
+		}
+		if fenced || !strings.Contains(line, "|") || i+1 >= len(lines) || !tableDelimiter.MatchString(strings.TrimSpace(lines[i+1])) {
+			out = append(out, lines[i])
+			continue
+		}
+
+		header := tableCells(line)
+		aligns := tableAligns(tableCells(strings.TrimSpace(lines[i+1])))
+		var rows [][]string
+		end := i + 2
+		for ; end < len(lines) && strings.Contains(lines[end], "|"); end++ {
+			rows = append(rows, tableCells(strings.TrimSpace(lines[end])))
+		}
+
+		if i == 0 {
+			// the first line follows the role label; the table gets its own
+			out = append(out, "")
+		}
+		out = append(out, strings.Split(drawTable(header, rows, aligns, width), "\n")...)
+		i = end - 1
+	}
+	return strings.Join(out, "\n")
+}
+
+// tableCells splits a row on its pipes, dropping the outer ones.
+func tableCells(row string) []string {
+	row = strings.TrimPrefix(strings.TrimSuffix(row, "|"), "|")
+	cells := strings.Split(row, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+func tableAligns(delimiter []string) []lipgloss.Position {
+	aligns := make([]lipgloss.Position, len(delimiter))
+	for i, cell := range delimiter {
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			aligns[i] = lipgloss.Center
+		case strings.HasSuffix(cell, ":"):
+			aligns[i] = lipgloss.Right
+		default:
+			aligns[i] = lipgloss.Left
+		}
+	}
+	return aligns
+}
+
+func drawTable(header []string, rows [][]string, aligns []lipgloss.Position, width int) string {
+	t := table.New().
+		Border(lipgloss.RoundedBorder()).
+		BorderStyle(timeStyle).
+		Headers(header...).
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			style := lipgloss.NewStyle().Padding(0, 1)
+			if col < len(aligns) {
+				style = style.Align(aligns[col])
+			}
+			if row == table.HeaderRow {
+				style = style.Inherit(tableHeaderStyle)
+			}
+			return style
+		})
+	if width > 0 && lipgloss.Width(t.String()) > width {
+		// columns shrink and their cells wrap
+		t = t.Width(width)
+	}
+	return t.String()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestTableDelimiter(t *testing.T) {
+	tests := map[string]bool{
+		"|---|---|":        true,
+		"---|---":          true,
+		"| :-- | --: |":    true,
+		"|:-:|":            true,
+		"---":              true,
+		"| - |":            true,
+		"|---|x|":          false,
+		"| a | b |":        false,
+		"|===|===|":        false,
+		"":                 false,
+		"| -- | -- | :: |": false,
+	}
+	for row, want := range tests {
+		if got := tableDelimiter.MatchString(row); got != want {
+			t.Errorf("tableDelimiter matches %q: %v, want %v", row, got, want)
+		}
+	}
+}
+
+func TestTableCells(t *testing.T) {
+	tests := map[string][]string{
+		"| a | b |":  {"a", "b"},
+		"a|b":        {"a", "b"},
+		"|  | x |":   {"", "x"},
+		"| only |":   {"only"},
+		"| a | b | ": {"a", "b", ""},
+	}
+	for row, want := range tests {
+		if cells := tableCells(row); !slices.Equal(cells, want) {
+			t.Errorf("tableCells(%q) = %q, want %q", row, cells, want)
+		}
+	}
+
+	aligns := tableAligns([]string{"---", ":--", "--:", ":-:"})
+	if want := []lipgloss.Position{lipgloss.Left, lipgloss.Left, lipgloss.Right, lipgloss.Center}; !slices.Equal(aligns, want) {
+		t.Errorf("tableAligns = %v, want %v", aligns, want)
+	}
+}
+
+func TestRenderTables(t *testing.T) {
+	table := "| Name | Size |\n|:-----|-----:|\n| a | 1 |\n| bb | 22 |"
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		lines []string // of the plain output
+	}{
+		{"no table", "a | b\nplain text", 0, []string{"a | b", "plain text"}},
+		{"a pipe without a delimiter row", "| a | b |\n| c | d |", 0, []string{"| a | b |", "| c | d |"}},
+		{"fenced", "```\n" + table + "\n```", 0, strings.Split("```\n"+table+"\n```", "\n")},
+		{
+			"a table after text", "Sizes:\n" + table + "\nthat is all", 0,
+			[]string{
+				"Sizes:",
+				"╭──────┬──────╮",
+				"│ Name │ Size │",
+				"├──────┼──────┤",
+				"│ a    │    1 │",
+				"│ bb   │   22 │",
+				"╰──────┴──────╯",
+				"that is all",
+			},
+		},
+	}
+	for _, tt := range tests {
+		lines := strings.Split(ansi.Strip(renderTables(tt.text, tt.width)), "\n")
+		if !slices.Equal(lines, tt.lines) {
+			t.Errorf("%s: rendered\n%s\nwant\n%s", tt.name, strings.Join(lines, "\n"), strings.Join(tt.lines, "\n"))
+		}
+	}
+
+	// a table on the first line starts on a line of its own
+	if lines := strings.Split(ansi.Strip(renderTables(table, 0)), "\n"); lines[0] != "" || !strings.HasPrefix(lines[1], "╭") {
+		t.Errorf("a leading table renders as %q", lines)
+	}
+
+	wide := "| " + strings.Repeat("word ", 30) + "| x |\n|---|---|\n| a | b |"
+	for _, line := range strings.Split(renderTables(wide, 40), "\n") {
+		if width := lipgloss.Width(line); width > 40 {
+			t.Errorf("a line is %d wide, more than 40: %q", width, ansi.Strip(line))
+		}
+	}
+}
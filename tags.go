@@ -0,0 +1,151 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Tags label sessions for the picker's filter. They are stored lower case
+// without the leading '#'.
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// tagSession adds (or with remove, drops) tags on the current chat.
+func (m *model) tagSession(args string, remove bool) {
+	names := strings.Fields(args)
+	if len(names) == 0 {
+		if len(m.meta.Tags) == 0 {
+			m.addMessage(RoleSystem, "No tags. Use /tag <name>... to add some")
+		} else {
+			m.addMessage(RoleSystem, "Tags: #"+strings.Join(m.meta.Tags, " #"))
+		}
+		return
+	}
+
+	for _, name := range names {
+		tag := normalizeTag(name)
+		at := slices.Index(m.meta.Tags, tag)
+		switch {
+		case tag == "":
+		case remove && at >= 0:
+			m.meta.Tags = slices.Delete(m.meta.Tags, at, at+1)
+		case !remove && at < 0:
+			m.meta.Tags = append(m.meta.Tags, tag)
+		}
+	}
+	sort.Strings(m.meta.Tags)
+	m.changed()
+	if m.currentId != 0 {
+		m.save()
+	}
+}
+
+// matchesFilter checks every word of the query: "#tag" words need a tag
+// starting with it, other words must appear in the session's name.
+func matchesFilter(session Session, query string) bool {
+	name := strings.ToLower(session.Name())
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if tag, ok := strings.CutPrefix(word, "#"); ok {
+			if !slices.ContainsFunc(session.Tags, func(t string) bool { return strings.HasPrefix(t, tag) }) {
+				return false
+			}
+		} else if !strings.Contains(name, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// completeTag extends a trailing "#partial" in the query to the longest
+// prefix shared by the known tags it matches.
+func completeTag(query string, known []string) string {
+	at := strings.LastIndexAny(query, " ") + 1
+	partial, ok := strings.CutPrefix(strings.ToLower(query[at:]), "#")
+	if !ok {
+		return query
+	}
+
+	var matches []string
+	for _, tag := range known {
+		if strings.HasPrefix(tag, partial) {
+			matches = append(matches, tag)
+		}
+	}
+	if len(matches) == 0 {
+		return query
+	}
+
+	common := matches[0]
+	for _, tag := range matches[1:] {
+		for !strings.HasPrefix(tag, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(matches) == 1 {
+		common += " "
+	}
+	return query[:at] + "#" + common
+}
+
+func knownTags(sessions []Session) []string {
+	tags := []string{}
+	for _, session := range sessions {
+		for _, tag := range session.Tags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+func (m model) openFilter() (model, tea.Cmd) {
+	if m.filter.Prompt == "" {
+		m.filter = textinput.New()
+		m.filter.Prompt = "Filter: "
+		m.filter.Placeholder = "text or #tag"
+	}
+	m.filtering = true
+	m.filter.Focus()
+	return m, textinput.Blink
+}
+
+// updateFilter edits the picker's filter; enter keeps it, esc clears it.
+func (m model) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.filter.SetValue("")
+		m.filtering = false
+		m.filter.Blur()
+		m.refreshSessions()
+		return m, nil
+	case "enter":
+		m.filtering = false
+		m.filter.Blur()
+		return m, nil
+	case "up":
+		m.menu.up()
+		return m, nil
+	case "down":
+		m.menu.down()
+		return m, nil
+	case "tab":
+		sessions, _ := listSessions(m.storage, m.archived)
+		m.filter.SetValue(completeTag(m.filter.Value(), knownTags(sessions)))
+		m.filter.CursorEnd()
+		m.refreshSessions()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.filter, cmd = m.filter.Update(msg)
+	m.refreshSessions()
+	return m, cmd
+}
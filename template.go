@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Template seeds a new session: a system prompt sent along with every
+// message and a few messages to start from.
+type Template struct {
+	Name     string            `json:"name"`
+	System   string            `json:"system"`
+	Messages []TemplateMessage `json:"messages"`
+	Provider string            `json:"provider"` // defaults to chat.provider
+	Model    string            `json:"model"`
+}
+
+type TemplateMessage struct {
+	Role string `json:"role"` // "user", "bot" or "system"
+	Text string `json:"text"`
+}
+
+func parseRole(name string) Role {
+	switch strings.ToLower(name) {
+	case "user":
+		return RoleUser
+	case "bot", "assistant":
+		return RoleBot
+	default:
+		return RoleSystem
+	}
+}
+
+// withSystem puts the session's system prompt in front of the input, the
+// only channel a one-shot CLI backend has for it.
+func withSystem(system, input string) string {
+	if system == "" {
+		return input
+	}
+	return system + "\n\n" + input
+}
+
+func (m *model) newFromTemplate(tmpl Template) {
+	if !m.newSession() {
+		return
+	}
+
+	m.meta.Template, m.meta.System = tmpl.Name, tmpl.System
+	if tmpl.Provider != "" {
+		m.meta.Provider, m.meta.Model = tmpl.Provider, tmpl.Model
+	}
+	for _, message := range tmpl.Messages {
+		m.messages = append(m.messages, Message{Role: parseRole(message.Role), Text: message.Text})
+	}
+	m.changed()
+	if len(m.messages) == 0 {
+		m.viewport.SetContent(fmt.Sprintf("New chat from template %q. Type a message below.", tmpl.Name))
+		return
+	}
+	m.refreshTranscript()
+	m.viewport.GotoBottom()
+}
+
+func (m model) openTemplates() (model, tea.Cmd) {
+	if len(m.templates) == 0 {
+		m.addMessage(RoleSystem, "No templates yet: add them under \"templates\" in "+configPath())
+		return m, nil
+	}
+
+	items := make([]menuItem, 0, len(m.templates))
+	for i, tmpl := range m.templates {
+		line, _, _ := strings.Cut(tmpl.System, "\n")
+		items = append(items, menuItem{title: tmpl.Name, detail: line, id: uint32(i)})
+	}
+	m.menu = menu{title: "New chat from template", hint: "enter start · esc close", items: items}
+	m.mode = modeTemplates
+	m.textarea.Blur()
+	return m, nil
+}
+
+func (m model) updateTemplates(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "alt+n":
+		return m.closeOverlay(), nil
+	case "up", "k":
+		m.menu.up()
+	case "down", "j":
+		m.menu.down()
+	case "enter":
+		item, ok := m.menu.selected()
+		m = m.closeOverlay()
+		if ok {
+			m.newFromTemplate(m.templates[item.id])
+		}
+	}
+	return m, nil
+}
+
+// setSystem shows the chat's system prompt, replaces it, or with "clear"
+// goes back to the configured one.
+func (m *model) setSystem(args string) {
+	switch args {
+	case "":
+		system, from := m.meta.System, "of this chat"
+		if system == "" {
+			system, from = m.chat.SystemPrompt(m.meta.Provider)
+		}
+		if system == "" {
+			m.addMessage(RoleSystem, "No system prompt; /system <prompt> sets one")
+			return
+		}
+		m.addMessage(RoleSystem, "System prompt "+from+": "+system)
+	case "clear":
+		m.meta.System = ""
+		if system, from := m.chat.SystemPrompt(m.meta.Provider); system != "" {
+			m.addMessage(RoleSystem, "System prompt "+from+": "+system)
+			return
+		}
+		m.addMessage(RoleSystem, "System prompt cleared")
+	default:
+		m.meta.System = args
+		m.addMessage(RoleSystem, "System prompt set")
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const DEFAULT_THEME = "default"
+
+// Color is a theme color. In the config file it is either one color for
+// every terminal or {"light": ..., "dark": ...}, picked by the terminal's
+// background. Colors are anything lipgloss.Color takes: ANSI numbers ("62")
+// or hex ("#7D56F4").
+type Color struct {
+	Light string `json:"light"`
+	Dark  string `json:"dark"`
+}
+
+func (c *Color) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*c = Color{Light: one, Dark: one}
+		return nil
+	}
+	type pair Color
+	return json.Unmarshal(data, (*pair)(c))
+}
+
+func adaptive(dark, light string) Color {
+	return Color{Light: light, Dark: dark}
+}
+
+func (c Color) terminal() lipgloss.TerminalColor {
+	return lipgloss.AdaptiveColor{Light: cmp.Or(c.Light, c.Dark), Dark: cmp.Or(c.Dark, c.Light)}
+}
+
+// pick resolves a color that is not drawn by lipgloss, like the chroma
+// style name.
+func (c Color) pick() string {
+	if lipgloss.HasDarkBackground() {
+		return cmp.Or(c.Dark, c.Light)
+	}
+	return cmp.Or(c.Light, c.Dark)
+}
+
+// Theme is the palette the styles are built from. Fields left empty in a
+// theme from the config file keep the default theme's color.
+type Theme struct {
+	Name      string `json:"name"`
+	Border    Color  `json:"border"`     // transcript border, titles
+	Muted     Color  `json:"muted"`      // details, times, the sidebar border
+	User      Color  `json:"user"`       // user messages
+	Bot       Color  `json:"bot"`        // bot messages, busy state
+	Selected  Color  `json:"selected"`   // cursors, toasts, unsaved state
+	Match     Color  `json:"match"`      // background of find matches
+	MatchText Color  `json:"match_text"` // text of find matches
+	Code      Color  `json:"code"`       // chroma style for code blocks
+}
+
+// the dark-only themes fall back to their dark colors on light terminals
+var builtinThemes = []Theme{
+	{
+		Name:      "default",
+		Border:    adaptive("62", "62"),
+		Muted:     adaptive("240", "244"),
+		User:      adaptive("205", "162"),
+		Bot:       adaptive("86", "30"),
+		Selected:  adaptive("205", "162"),
+		Match:     adaptive("58", "187"),
+		MatchText: adaptive("230", "0"),
+		Code:      adaptive(HIGHLIGHT_STYLE, "monokailight"),
+	},
+	{
+		Name:      "dracula",
+		Border:    adaptive("#bd93f9", ""),
+		Muted:     adaptive("#6272a4", ""),
+		User:      adaptive("#ff79c6", ""),
+		Bot:       adaptive("#50fa7b", ""),
+		Selected:  adaptive("#ff79c6", ""),
+		Match:     adaptive("#44475a", ""),
+		MatchText: adaptive("#f1fa8c", ""),
+		Code:      adaptive("dracula", ""),
+	},
+	{
+		Name:      "gruvbox",
+		Border:    adaptive("#d79921", "#b57614"),
+		Muted:     adaptive("#928374", "#7c6f64"),
+		User:      adaptive("#fe8019", "#af3a03"),
+		Bot:       adaptive("#b8bb26", "#79740e"),
+		Selected:  adaptive("#fe8019", "#af3a03"),
+		Match:     adaptive("#504945", "#d5c4a1"),
+		MatchText: adaptive("#fabd2f", "#3c3836"),
+		Code:      adaptive("gruvbox", "gruvbox-light"),
+	},
+	{
+		Name:      "nord",
+		Border:    adaptive("#81a1c1", ""),
+		Muted:     adaptive("#4c566a", ""),
+		User:      adaptive("#b48ead", ""),
+		Bot:       adaptive("#88c0d0", ""),
+		Selected:  adaptive("#ebcb8b", ""),
+		Match:     adaptive("#434c5e", ""),
+		MatchText: adaptive("#eceff4", ""),
+		Code:      adaptive("nord", ""),
+	},
+	{
+		Name:      "mono",
+		Border:    adaptive("7", "8"),
+		Muted:     adaptive("8", "8"),
+		User:      adaptive("15", "0"),
+		Bot:       adaptive("7", "8"),
+		Selected:  adaptive("15", "0"),
+		Match:     adaptive("8", "7"),
+		MatchText: adaptive("15", "0"),
+		Code:      adaptive("bw", "bw"),
+	},
+}
+
+// findTheme looks the name up in the config's themes first, so a user theme
+// can replace a built-in one.
+func findTheme(name string, custom []Theme) (Theme, bool) {
+	for _, themes := range [][]Theme{custom, builtinThemes} {
+		for _, theme := range themes {
+			if theme.Name == name {
+				return theme.withDefaults(), true
+			}
+		}
+	}
+	return builtinThemes[0], false
+}
+
+func (t Theme) withDefaults() Theme {
+	base := builtinThemes[0]
+	t.Border = cmp.Or(t.Border, base.Border)
+	t.Muted = cmp.Or(t.Muted, base.Muted)
+	t.User = cmp.Or(t.User, base.User)
+	t.Bot = cmp.Or(t.Bot, base.Bot)
+	t.Selected = cmp.Or(t.Selected, base.Selected)
+	t.Match = cmp.Or(t.Match, base.Match)
+	t.MatchText = cmp.Or(t.MatchText, base.MatchText)
+	t.Code = cmp.Or(t.Code, base.Code)
+	return t
+}
+
+// applyTheme sets the colors of every style. It runs once at startup,
+// before the first frame.
+func applyTheme(t Theme) {
+	border, muted := t.Border.terminal(), t.Muted.terminal()
+	selected := t.Selected.terminal()
+
+	viewportStyle = viewportStyle.BorderForeground(border)
+	textareaStyle = textareaStyle.BorderForeground(muted)
+	sidebarStyle = sidebarStyle.BorderForeground(muted)
+	timeStyle = timeStyle.Foreground(muted)
+	messageStyle = messageStyle.Foreground(t.User.terminal())
+	systemMessageStyle = systemMessageStyle.Foreground(t.User.terminal())
+	botMessageStyle = botMessageStyle.Foreground(t.Bot.terminal())
+
+	menuTitleStyle = menuTitleStyle.Foreground(border)
+	menuSelectedStyle = menuSelectedStyle.Foreground(selected)
+	menuDetailStyle = menuDetailStyle.Foreground(muted)
+
+	statusStyle = statusStyle.Foreground(muted)
+	statusDirtyStyle = statusDirtyStyle.Foreground(selected)
+	statusBusyStyle = statusBusyStyle.Foreground(t.Bot.terminal())
+	toastStyle = toastStyle.Foreground(selected)
+	focusStyle = focusStyle.Foreground(selected)
+	chipStyle = chipStyle.Foreground(t.Bot.terminal())
+
+	findMatchStyle = findMatchStyle.Background(t.Match.terminal()).Foreground(t.MatchText.terminal())
+	findCurrentStyle = findCurrentStyle.Background(selected).Foreground(lipgloss.AdaptiveColor{Light: "15", Dark: "0"})
+
+	highlightStyle = t.Code.pick()
+}
+
+// loadTheme applies the configured theme, falling back to the default one
+// when the name is unknown. background is "dark" or "light" to skip asking
+// the terminal, which not every terminal answers.
+func loadTheme(name, background string, custom []Theme) {
+	switch background {
+	case "dark":
+		lipgloss.SetHasDarkBackground(true)
+	case "light":
+		lipgloss.SetHasDarkBackground(false)
+	}
+
+	theme, ok := findTheme(name, custom)
+	if !ok {
+		fmt.Printf("Unknown theme %q, using %q\n", name, DEFAULT_THEME)
+	}
+	applyTheme(theme)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const TITLE_WORDS = 5
+
+type titleMsg struct {
+	first string // first user message of the chat the title was asked for
+	title string
+}
+
+// wantsTitle is true right after the first exchange of a chat nobody has
+// named yet, if it got an answer (failures are system notes). echo would
+// only repeat the request back, so its chats keep the first prompt.
+func (m model) wantsTitle() bool {
+	prompts := 0
+	for _, message := range m.messages {
+		if message.Role == RoleUser {
+			prompts++
+		}
+	}
+	return m.autoTitle && m.meta.Title == "" && prompts == 1 && m.meta.Provider != "echo" &&
+		m.messages[len(m.messages)-1].Role == RoleBot
+}
+
+func firstPrompt(messages []Message) string {
+	for _, message := range messages {
+		if message.Role == RoleUser {
+			return message.Text
+		}
+	}
+	return ""
+}
+
+// generateTitle asks the backend to name the conversation in the
+// background. Failures are dropped; the preview is shown instead.
+func generateTitle(ctx context.Context, meta sessionMeta, messages []Message) tea.Cmd {
+	first := firstPrompt(messages)
+	return func() tea.Msg {
+		var b strings.Builder
+		b.WriteString("Reply with only a title of at most five words for this conversation.\n\n")
+		for _, message := range messages {
+			b.WriteString(message.Role.Label() + ": " + message.Text + "\n")
+		}
+
+		out, err := askBackend(ctx, meta.Provider, meta.Model, b.String())
+		if err != nil {
+			return nil
+		}
+		return titleMsg{first: first, title: cleanTitle(out)}
+	}
+}
+
+// cleanTitle keeps the first line of the reply, without quotes or a
+// trailing full stop, cut to TITLE_WORDS words.
+func cleanTitle(reply string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(reply), "\n")
+	words := strings.Fields(strings.Trim(line, "\"'`*# "))
+	if len(words) > TITLE_WORDS {
+		words = words[:TITLE_WORDS]
+	}
+	return strings.TrimRight(strings.Join(words, " "), ".")
+}
+
+// applyTitle stores a generated title unless the chat changed or was named
+// by hand while the backend was busy.
+func (m *model) applyTitle(msg titleMsg) {
+	if msg.title == "" || m.meta.Title != "" || firstPrompt(m.messages) != msg.first {
+		return
+	}
+	m.rename(msg.title)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const (
+	TOAST_DURATION = 3 * time.Second
+	NOTICE_LOG_MAX = 200 // notifications kept for the log (alt+l)
+)
+
+var toastStyle = lipgloss.NewStyle()
+
+// toastExpiredMsg clears the toast it was scheduled for, unless a newer one
+// has replaced it since.
+type toastExpiredMsg struct{ id int }
+
+// showToast puts a short confirmation on the transcript's bottom border
+// instead of adding a message to the chat.
+func (m *model) showToast(text string) tea.Cmd {
+	m.toastId++
+	m.toast = text
+	id := m.toastId
+	return tea.Tick(TOAST_DURATION, func(time.Time) tea.Msg { return toastExpiredMsg{id: id} })
+}
+
+// notice is a notification from storage, sync or export, kept so the log
+// can show what scrolled by as toasts.
+type notice struct {
+	at   time.Time
+	text string
+}
+
+// notify shows text as a toast and records it in the log.
+func (m *model) notify(text string) tea.Cmd {
+	m.notices = append(m.notices, notice{at: time.Now(), text: text})
+	if len(m.notices) > NOTICE_LOG_MAX {
+		m.notices = m.notices[len(m.notices)-NOTICE_LOG_MAX:]
+	}
+	return m.showToast(text)
+}
+
+func (m model) openNotices() (model, tea.Cmd) {
+	items := make([]menuItem, 0, len(m.notices))
+	for _, n := range m.notices {
+		items = append(items, menuItem{title: n.text, detail: n.at.Format("15:04:05")})
+	}
+	m.menu = menu{title: "Notifications", hint: "esc close", items: items, cursor: len(items) - 1}
+	m.mode = modeNotices
+	m.textarea.Blur()
+	return m, nil
+}
+
+func (m model) updateNotices(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q", "alt+l":
+		return m.closeOverlay(), nil
+	case "up", "k":
+		m.menu.up()
+	case "down", "j":
+		m.menu.down()
+	}
+	return m, nil
+}
+
+// toastBorder writes text into the bottom edge of a box rendered with
+// frame, and position (see scrollPosition) at its right end.
+func toastBorder(box, text, position string, frame lipgloss.Style) string {
+	at := strings.LastIndex(box, "\n")
+	if at < 0 || text == "" && position == "" {
+		return box
+	}
+	bottom := box[at+1:]
+	width := lipgloss.Width(bottom)
+	if width < 6 {
+		return box
+	}
+
+	border := lipgloss.RoundedBorder()
+	right, end := "", border.BottomRight
+	if position != "" {
+		right, end = " "+position+" ", border.Bottom+border.BottomRight
+	}
+	label := ""
+	if text != "" {
+		label = " " + ansi.Truncate(text, max(width-6-lipgloss.Width(right+end), 1), "…") + " "
+	}
+	fill := strings.Repeat(border.Bottom, max(width-2-lipgloss.Width(label+right+end), 0))
+	borderStyle := lipgloss.NewStyle().Foreground(frame.GetBorderBottomForeground())
+	bottom = borderStyle.Render(border.BottomLeft+border.Bottom) + toastStyle.Render(label) + borderStyle.Render(fill) + timeStyle.Render(right) + borderStyle.Render(end)
+	return box[:at+1] + bottom
+}